@@ -0,0 +1,148 @@
+package timewheel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTimeWheelStopRaceDoesNotHang 让大量goroutine并发调用AddTask/UpdateTask/RemoveTask,
+// 同时另一个goroutine并发调用Stop()。在reserveAndSend引入sendWG之前, stopped的判断和
+// channel发送不是同一个临界区, select可能在"channel有空位"和"stopChannel已关闭"之间
+// 随机选中前者, 调用方拿到nil却再也不会被consumer处理, 其pendingPos占位永久卡住该key。
+// 这里不追求覆盖所有交织, 只验证: 不panic、不死锁(否则测试超时), 且每次调用都能在
+// ErrStopped和nil之间给出一个站得住脚的结果
+func TestTimeWheelStopRaceDoesNotHang(t *testing.T) {
+	const goroutines = 40
+
+	// interval使用整秒: getPositionAndCircle以tw.interval.Seconds()为除数计算圈数,
+	// 亚秒级interval会截断成0导致除零panic, 这是与本次修复无关的既有限制, 测试绕开它
+	tw := New(time.Second, 8)
+	tw.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := fmt.Sprintf("key-%d", i)
+				if err := tw.AddTask(time.Second, 1, key, nil, func(TaskData) {}); err != nil && err != ErrStopped {
+					t.Errorf("AddTask: unexpected error %v", err)
+				}
+				if err := tw.UpdateTask(key, 2*time.Second, nil); err != nil && err != ErrStopped {
+					t.Errorf("UpdateTask: unexpected error %v", err)
+				}
+				if err := tw.RemoveTask(key); err != nil && err != ErrStopped {
+					t.Errorf("RemoveTask: unexpected error %v", err)
+				}
+			}(i)
+		}
+
+		go tw.Stop()
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddTask/UpdateTask/RemoveTask raced with Stop() and never returned")
+	}
+}
+
+// TestHierarchicalStopRaceDoesNotHang 与TestTimeWheelStopRaceDoesNotHang相同, 但针对
+// HierarchicalTimeWheel的reserveAndSend/sendWG实现
+func TestHierarchicalStopRaceDoesNotHang(t *testing.T) {
+	const goroutines = 40
+
+	htw := NewHierarchical(5*time.Millisecond, []int{4, 4})
+	htw.Start()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		var wg sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := fmt.Sprintf("key-%d", i)
+				if err := htw.AddTask(50*time.Millisecond, 1, key, nil, func(TaskData) {}); err != nil && err != ErrStopped {
+					t.Errorf("AddTask: unexpected error %v", err)
+				}
+				if err := htw.UpdateTask(key, 80*time.Millisecond, nil); err != nil && err != ErrStopped {
+					t.Errorf("UpdateTask: unexpected error %v", err)
+				}
+				if err := htw.RemoveTask(key); err != nil && err != ErrStopped {
+					t.Errorf("RemoveTask: unexpected error %v", err)
+				}
+			}(i)
+		}
+
+		go htw.Stop()
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddTask/UpdateTask/RemoveTask raced with Stop() and never returned")
+	}
+}
+
+// TestTimeWheelAddTaskDuplicateKeyIsExclusive 并发用同一个key调用AddTask,
+// 必须恰好有一次成功, 其余全部返回ErrDuplicateKey, 不能出现两次都成功(TOCTOU)
+func TestTimeWheelAddTaskDuplicateKeyIsExclusive(t *testing.T) {
+	const attempts = 20
+
+	tw := New(time.Second, 8) // 避免亚秒级interval触发getPositionAndCircle的既有除零限制
+	tw.Start()
+	defer tw.Stop()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	successes := 0
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := tw.AddTask(time.Second, 1, "dup-key", nil, func(TaskData) {}); err == nil {
+				mu.Lock()
+				successes++
+				mu.Unlock()
+			} else if err != ErrDuplicateKey {
+				t.Errorf("AddTask: unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Fatalf("successes = %d, want exactly 1", successes)
+	}
+}
+
+// TestTimeWheelUpdateTaskRejectsInvalidInterval 回归测试: UpdateTask此前不校验interval,
+// 一个负的interval会一路传到getPositionAndCircle, 算出负的槽位下标, 在start()唯一的
+// 事件循环goroutine里引发panic, 拖垮整个时间轮
+func TestTimeWheelUpdateTaskRejectsInvalidInterval(t *testing.T) {
+	tw := New(time.Second, 8)
+	tw.Start()
+	defer tw.Stop()
+
+	if err := tw.AddTask(time.Second, 1, "k", nil, func(TaskData) {}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if err := tw.UpdateTask("k", -5*time.Second, nil); err != ErrInvalidTask {
+		t.Fatalf("UpdateTask with negative interval = %v, want ErrInvalidTask", err)
+	}
+	if err := tw.UpdateTask("k", 0, nil); err != ErrInvalidTask {
+		t.Fatalf("UpdateTask with zero interval = %v, want ErrInvalidTask", err)
+	}
+}
@@ -0,0 +1,216 @@
+package timewheel
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AddTaskAt 在绝对时间点 t 触发一次任务
+func (tw *TimeWheel) AddTaskAt(t time.Time, key interface{}, data TaskData, job Job) error {
+	return tw.AddTask(delayUntil(t), 1, key, data, job)
+}
+
+// AddCron 按照cron表达式(分 时 日 月 周)周期性调度任务。每次触发执行完用户的job后,
+// 会根据表达式计算下一次匹配的时刻并重新调度自身
+func (tw *TimeWheel) AddCron(spec string, key interface{}, data TaskData, job Job) error {
+	sched, err := parseCronSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	reschedule := newCronReschedule(key, sched.next, tw.AddTaskAt, tw.pool.onPanic, job)
+	return tw.AddTaskAt(sched.next(time.Now()), key, data, reschedule)
+}
+
+// AddTaskAt 在绝对时间点 t 触发一次任务
+func (htw *HierarchicalTimeWheel) AddTaskAt(t time.Time, key interface{}, data TaskData, job Job) error {
+	return htw.AddTask(delayUntil(t), 1, key, data, job)
+}
+
+// AddCron 按照cron表达式(分 时 日 月 周)周期性调度任务。每次触发执行完用户的job后,
+// 会根据表达式计算下一次匹配的时刻并重新调度自身
+func (htw *HierarchicalTimeWheel) AddCron(spec string, key interface{}, data TaskData, job Job) error {
+	sched, err := parseCronSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	reschedule := newCronReschedule(key, sched.next, htw.AddTaskAt, htw.pool.onPanic, job)
+	return htw.AddTaskAt(sched.next(time.Now()), key, data, reschedule)
+}
+
+// newCronReschedule 包装job, 使其每次执行完后根据next算出的下一次匹配时刻通过addTaskAt
+// 重新调度自身, TimeWheel.AddCron和HierarchicalTimeWheel.AddCron共用这一份逻辑。
+// defer确保即便job(d)发生panic, cron链也会继续排到下一次触发, 而不是就此中断;
+// StripJobContext去掉WithJobTimeout注入的(本次已失效的)context, 避免它随d一起被当作
+// 下一次触发的TaskData持久化下去; addTaskAt失败(例如时间轮恰好在这一刻被Stop(),
+// 或next()算出的时刻超出了HierarchicalTimeWheel.MaxDelay())意味着cron链就此断掉且
+// 不会再有任何信号, 借onPanic钩子把它报告出去, 而不是静默吞掉
+func newCronReschedule(key interface{}, next func(time.Time) time.Time, addTaskAt func(time.Time, interface{}, TaskData, Job) error, onPanic func(interface{}, interface{}, []byte), job Job) Job {
+	var reschedule Job
+	reschedule = func(d TaskData) {
+		defer func() {
+			if err := addTaskAt(next(time.Now()), key, StripJobContext(d), reschedule); err != nil && onPanic != nil {
+				onPanic(key, fmt.Errorf("timewheel: cron reschedule for key %v failed: %w", key, err), nil)
+			}
+		}()
+		job(d)
+	}
+	return reschedule
+}
+
+// delayUntil 将绝对时间转换为相对当前时刻的延迟, 过去的时间点视为立即触发
+func delayUntil(t time.Time) time.Duration {
+	d := time.Until(t)
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+	return d
+}
+
+// cronField 一个cron字段解析后的合法取值集合(已排序去重)
+type cronField []int
+
+func (f cronField) has(v int) bool {
+	for _, x := range f {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// cronSchedule 解析后的标准5字段(分 时 日 月 周)cron表达式
+type cronSchedule struct {
+	minute  cronField
+	hour    cronField
+	day     cronField
+	month   cronField
+	weekday cronField
+}
+
+// parseCronSpec 解析标准5字段cron表达式: 分钟 小时 日 月 星期。
+// 每个字段支持 *、范围(1-5)、步长(*/15)、列表(1,3,5)及其组合
+func parseCronSpec(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("timewheel: cron spec must have 5 fields, got %d: %q", len(fields), spec)
+	}
+
+	minute, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+	hour, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+	day, err := parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+	month, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+	weekday, err := parseCronField(fields[4], 0, 6)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cronSchedule{minute: minute, hour: hour, day: day, month: month, weekday: weekday}, nil
+}
+
+// parseCronField 解析单个cron字段
+func parseCronField(field string, min, max int) (cronField, error) {
+	set := make(map[int]struct{})
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		rangePart := part
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("timewheel: invalid cron step %q", part)
+			}
+			step = s
+			rangePart = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+			// 使用字段的完整取值范围
+		case strings.Contains(rangePart, "-"):
+			idx := strings.Index(rangePart, "-")
+			l, err1 := strconv.Atoi(rangePart[:idx])
+			h, err2 := strconv.Atoi(rangePart[idx+1:])
+			if err1 != nil || err2 != nil || l < min || h > max || l > h {
+				return nil, fmt.Errorf("timewheel: invalid cron range %q", part)
+			}
+			lo, hi = l, h
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil || v < min || v > max {
+				return nil, fmt.Errorf("timewheel: invalid cron value %q", part)
+			}
+			lo, hi = v, v
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = struct{}{}
+		}
+	}
+
+	if len(set) == 0 {
+		return nil, fmt.Errorf("timewheel: empty cron field %q", field)
+	}
+
+	result := make(cronField, 0, len(set))
+	for v := range set {
+		result = append(result, v)
+	}
+	sort.Ints(result)
+	return result, nil
+}
+
+// next 返回从 from 之后最近一个匹配cron表达式的整分钟时刻
+func (s *cronSchedule) next(from time.Time) time.Time {
+	from = from.Add(time.Second) // 从下一秒开始寻找, 避免与刚触发的这一分钟重复匹配
+	candidate := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), from.Minute(), 0, 0, from.Location())
+	if candidate.Before(from) {
+		candidate = candidate.Add(time.Minute)
+	}
+
+	// 表达式本身无法满足时(例如2月30日)最多向后查找4年后放弃, 返回查找上限
+	limit := candidate.AddDate(4, 0, 0)
+	for candidate.Before(limit) {
+		if s.month.has(int(candidate.Month())) && s.matchesDay(candidate) &&
+			s.hour.has(candidate.Hour()) && s.minute.has(candidate.Minute()) {
+			return candidate
+		}
+		candidate = candidate.Add(time.Minute)
+	}
+
+	return limit
+}
+
+// matchesDay 日期字段和星期字段按标准cron语义为"或"关系: 只要有一个非通配字段匹配即可,
+// 两者都是通配符时始终匹配
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dayWild := len(s.day) == 31
+	weekdayWild := len(s.weekday) == 7
+	switch {
+	case dayWild && weekdayWild:
+		return true
+	case dayWild:
+		return s.weekday.has(int(t.Weekday()))
+	case weekdayWild:
+		return s.day.has(t.Day())
+	default:
+		return s.day.has(t.Day()) || s.weekday.has(int(t.Weekday()))
+	}
+}
@@ -0,0 +1,230 @@
+package timewheel
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	cases := []struct {
+		name    string
+		field   string
+		min     int
+		max     int
+		want    cronField
+		wantErr bool
+	}{
+		{"wildcard", "*", 0, 59, cronField{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, false}, // 只核对前几个, 下面单独截断比较
+		{"single value", "5", 0, 59, cronField{5}, false},
+		{"range", "1-3", 0, 59, cronField{1, 2, 3}, false},
+		{"step", "*/15", 0, 59, cronField{0, 15, 30, 45}, false},
+		{"list", "1,3,5", 0, 59, cronField{1, 3, 5}, false},
+		{"range with step", "0-10/5", 0, 59, cronField{0, 5, 10}, false},
+		{"dedup and sort", "5,1,5,1", 0, 59, cronField{1, 5}, false},
+		{"value out of range", "60", 0, 59, nil, true},
+		{"range out of order", "5-1", 0, 59, nil, true},
+		{"invalid step", "*/0", 0, 59, nil, true},
+		{"not a number", "abc", 0, 59, nil, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseCronField(c.field, c.min, c.max)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseCronField(%q) error = nil, want error", c.field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCronField(%q): %v", c.field, err)
+			}
+			if c.name == "wildcard" {
+				if len(got) != 60 {
+					t.Fatalf("parseCronField(*) len = %d, want 60", len(got))
+				}
+				if !reflect.DeepEqual(got[:len(c.want)], c.want) {
+					t.Fatalf("parseCronField(*)[:%d] = %v, want %v", len(c.want), got[:len(c.want)], c.want)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("parseCronField(%q) = %v, want %v", c.field, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseCronSpec(t *testing.T) {
+	if _, err := parseCronSpec("* * * *"); err == nil {
+		t.Fatal("parseCronSpec with 4 fields: error = nil, want error")
+	}
+	if _, err := parseCronSpec("60 * * * *"); err == nil {
+		t.Fatal("parseCronSpec with out-of-range minute: error = nil, want error")
+	}
+
+	sched, err := parseCronSpec("30 2 * * 1-5")
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+	if !sched.minute.has(30) || sched.minute.has(31) {
+		t.Fatalf("minute field = %v, want just {30}", sched.minute)
+	}
+	if !sched.hour.has(2) || sched.hour.has(3) {
+		t.Fatalf("hour field = %v, want just {2}", sched.hour)
+	}
+	if !sched.weekday.has(1) || !sched.weekday.has(5) || sched.weekday.has(0) || sched.weekday.has(6) {
+		t.Fatalf("weekday field = %v, want {1..5}", sched.weekday)
+	}
+}
+
+// TestCronScheduleNextWithinSameDay 验证next()在同一天内找到下一个匹配的整分钟时刻
+func TestCronScheduleNextWithinSameDay(t *testing.T) {
+	sched, err := parseCronSpec("30 14 * * *") // 每天14:30
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	from := time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 3, 5, 14, 30, 0, 0, time.UTC)
+	if got := sched.next(from); !got.Equal(want) {
+		t.Fatalf("next(%v) = %v, want %v", from, got, want)
+	}
+}
+
+// TestCronScheduleNextRollsOverToNextDay 验证当天已经过了目标时刻时, next()正确滚动到第二天
+func TestCronScheduleNextRollsOverToNextDay(t *testing.T) {
+	sched, err := parseCronSpec("30 14 * * *")
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	from := time.Date(2026, 3, 5, 15, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 3, 6, 14, 30, 0, 0, time.UTC)
+	if got := sched.next(from); !got.Equal(want) {
+		t.Fatalf("next(%v) = %v, want %v", from, got, want)
+	}
+}
+
+// TestCronScheduleNextRollsOverYearBoundary 验证next()能跨年滚动(12月31日 -> 1月1日)
+func TestCronScheduleNextRollsOverYearBoundary(t *testing.T) {
+	sched, err := parseCronSpec("0 0 1 1 *") // 每年1月1日0点
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	from := time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC)
+	want := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := sched.next(from); !got.Equal(want) {
+		t.Fatalf("next(%v) = %v, want %v", from, got, want)
+	}
+}
+
+// TestCronScheduleNextLeapDay 验证next()能找到下一个2月29日, 只在闰年出现
+func TestCronScheduleNextLeapDay(t *testing.T) {
+	sched, err := parseCronSpec("0 0 29 2 *") // 每年2月29日(仅闰年)0点
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	from := time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC) // 2027不是闰年, 下一个2月29日在2028
+	want := time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)
+	if got := sched.next(from); !got.Equal(want) {
+		t.Fatalf("next(%v) = %v, want %v", from, got, want)
+	}
+}
+
+// TestCronScheduleNextImpossibleDateGivesUp 验证日期字段永远无法满足(2月30日不存在)时,
+// next()按文档描述放弃并返回4年后的查找上限, 而不是死循环或panic
+func TestCronScheduleNextImpossibleDateGivesUp(t *testing.T) {
+	sched, err := parseCronSpec("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	from := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	got := sched.next(from)
+
+	// 复刻next()内部对"查找起点"的计算方式: from+1秒后截断到整分钟, 早于from+1秒的话再进一位
+	candidate := time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), from.Minute(), 0, 0, from.Location())
+	if candidate.Before(from.Add(time.Second)) {
+		candidate = candidate.Add(time.Minute)
+	}
+	wantLimit := candidate.AddDate(4, 0, 0)
+
+	if !got.Equal(wantLimit) {
+		t.Fatalf("next(%v) = %v, want the 4-year search limit %v", from, got, wantLimit)
+	}
+}
+
+// TestCronScheduleMatchesDay 验证day和weekday字段均非通配时是"或"关系
+func TestCronScheduleMatchesDay(t *testing.T) {
+	sched, err := parseCronSpec("0 0 1 * 1") // 每月1号或每周一
+	if err != nil {
+		t.Fatalf("parseCronSpec: %v", err)
+	}
+
+	monday := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) // 周一, 非1号
+	if !sched.matchesDay(monday) {
+		t.Fatalf("matchesDay(%v) = false, want true (weekday matches)", monday)
+	}
+	firstOfMonth := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) // 周日, 但是1号
+	if !sched.matchesDay(firstOfMonth) {
+		t.Fatalf("matchesDay(%v) = false, want true (day-of-month matches)", firstOfMonth)
+	}
+	neither := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC) // 周二, 3号
+	if sched.matchesDay(neither) {
+		t.Fatalf("matchesDay(%v) = true, want false", neither)
+	}
+}
+
+// TestNewCronRescheduleSurvivesJobPanic 回归测试: reschedule闭包必须通过defer在job(d)
+// panic的情况下仍然调用addTaskAt重新排程, 而不是让cron链就此断掉
+func TestNewCronRescheduleSurvivesJobPanic(t *testing.T) {
+	var rescheduled bool
+	addTaskAt := func(t time.Time, key interface{}, data TaskData, job Job) error {
+		rescheduled = true
+		return nil
+	}
+
+	reschedule := newCronReschedule("k", func(time.Time) time.Time { return time.Now() }, addTaskAt, nil, func(TaskData) {
+		panic("job blew up")
+	})
+
+	func() {
+		defer func() { recover() }()
+		reschedule(nil)
+	}()
+
+	if !rescheduled {
+		t.Fatal("addTaskAt was not called after job(d) panicked, cron chain is broken")
+	}
+}
+
+// TestNewCronRescheduleReportsAddTaskAtFailureViaOnPanic 验证addTaskAt失败时(例如时间轮
+// 已经Stop()), reschedule通过onPanic钩子把这次断链报告出去, 而不是静默吞掉
+func TestNewCronRescheduleReportsAddTaskAtFailureViaOnPanic(t *testing.T) {
+	wantErr := errors.New("wheel stopped")
+	addTaskAt := func(t time.Time, key interface{}, data TaskData, job Job) error {
+		return wantErr
+	}
+
+	var gotKey interface{}
+	var gotErr error
+	onPanic := func(key interface{}, r interface{}, stack []byte) {
+		gotKey = key
+		gotErr, _ = r.(error)
+	}
+
+	reschedule := newCronReschedule("k", func(time.Time) time.Time { return time.Now() }, addTaskAt, onPanic, func(TaskData) {})
+	reschedule(nil)
+
+	if gotKey != "k" {
+		t.Fatalf("onPanic key = %v, want %q", gotKey, "k")
+	}
+	if gotErr == nil || !errors.Is(gotErr, wantErr) {
+		t.Fatalf("onPanic err = %v, want wrapping %v", gotErr, wantErr)
+	}
+}
@@ -0,0 +1,16 @@
+package timewheel
+
+import "errors"
+
+var (
+	// ErrStopped 时间轮已经停止, 不再接受新的任务操作
+	ErrStopped = errors.New("timewheel: already stopped")
+	// ErrDuplicateKey 任务key已存在
+	ErrDuplicateKey = errors.New("timewheel: duplicate key")
+	// ErrInvalidTask 任务参数非法
+	ErrInvalidTask = errors.New("timewheel: invalid task")
+	// ErrInvalidKey key为nil
+	ErrInvalidKey = errors.New("timewheel: invalid key")
+	// ErrDelayTooLarge 任务延迟超出了HierarchicalTimeWheel.MaxDelay()能表示的范围
+	ErrDelayTooLarge = errors.New("timewheel: delay exceeds wheel's maximum representable span")
+)
@@ -0,0 +1,509 @@
+package timewheel
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hLevel 多级时间轮中的一级, 槽位时间单位为上一级的 interval*slotNum
+type hLevel struct {
+	interval   time.Duration //该级每个槽代表的时间跨度
+	slotNum    int           //该级槽位数量
+	slots      []*list.List  //该级轮盘
+	currentPos int           //该级当前指针位置
+}
+
+// htask 多级时间轮任务, 使用绝对到期时间, 级联降级时据此重新计算所在级别和槽位
+type htask struct {
+	interval time.Duration //时间间隔, 用于周期任务重新计算下一次到期时间
+	deadline time.Time     //绝对到期时间
+	times    int           //-1:无限次 >=1:指定运行次数
+	key      interface{}   //定时器唯一标识
+	job      Job           //回调函数
+	taskData TaskData      //回调函数参数
+	jobName  string        //通过RegisterJob注册的job名, 非空时该任务会被镜像到storage
+}
+
+// hPosition 任务在多级时间轮中的位置
+type hPosition struct {
+	level int
+	pos   int
+}
+
+// pendingLevel 是htw.timer中key的占位level, 表示该key已被reserveAndSend预占但实际任务尚未插入
+// 任何槽位。真正的level恒为[0, len(levels)), 因此-1可以安全地用作哨兵值
+const pendingLevel = -1
+
+// HierarchicalTimeWheel 多级时间轮, 由多个粒度递增的级联轮盘组成,
+// 用于避免单级 TimeWheel 对长延迟任务需要巨量 circle 递减的问题
+type HierarchicalTimeWheel struct {
+	levels []*hLevel
+	timer  map[interface{}]hPosition //任务位置记录器
+
+	tickInterval time.Duration //最底层槽位时间单位
+	ticker       *time.Ticker
+
+	addTaskChannel    chan htask       //新增任务channel
+	updateTaskChannel chan uptask      //更新任务channel
+	removeTaskChannel chan interface{} //删除任务channel
+	stopChannel       chan struct{}    //停止定时器channel, 只会被close一次
+	stopOnce          sync.Once        //保证stopChannel只被close一次
+	stopped           bool             //由mu保护, Stop()置true后新的发送方法调用一律返回ErrStopped
+	sendWG            sync.WaitGroup   //Stop()据此等待所有已经通过stopped判断、正在发送的调用完成, 才去关闭stopChannel
+	mu                sync.RWMutex     //保护timer和各级slots, 使List/Stats等巡检接口可以安全并发读取
+	storage           Storage          //可选的持久化存储后端
+	pool              *jobPool         //执行到期任务回调的worker池
+	maxSpan           time.Duration    //所有级别能够表示的最大延迟, 超出此范围的延迟会在locate中静默回绕
+
+	tasksFired   uint64          //累计已触发的任务数, 用于Stats()
+	tasksDropped uint64          //累计因过载等原因被丢弃的任务数, 用于Stats()
+	statsMu      sync.Mutex      //保护lastTick/tickLag/jobDurations
+	lastTick     time.Time       //上一次第0级tick发生的时间, 用于计算tickLag
+	tickLag      time.Duration   //最近一次tick相对期望触发时间的滞后
+	jobDurations []time.Duration //最近若干次job执行耗时的采样, 用于估算mean/p95
+}
+
+// NewHierarchical 创建多级时间轮
+// interval 为第0级(最细粒度)的槽位时间单位, slotsPerLevel 依次指定每一级的槽位数量,
+// 每一级的槽位时间单位等于上一级槽位时间单位乘以上一级槽位数量
+func NewHierarchical(interval time.Duration, slotsPerLevel []int, opts ...Option) *HierarchicalTimeWheel {
+	if interval <= 0 || len(slotsPerLevel) == 0 {
+		return nil
+	}
+
+	o := defaultWheelOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	htw := &HierarchicalTimeWheel{
+		tickInterval:      interval,
+		timer:             make(map[interface{}]hPosition),
+		addTaskChannel:    make(chan htask, o.chanSize),
+		updateTaskChannel: make(chan uptask, o.chanSize),
+		removeTaskChannel: make(chan interface{}, o.chanSize),
+		stopChannel:       make(chan struct{}),
+		storage:           o.storage,
+	}
+
+	levelInterval := interval
+	for _, slotNum := range slotsPerLevel {
+		if slotNum <= 0 {
+			return nil
+		}
+		level := &hLevel{interval: levelInterval, slotNum: slotNum, slots: make([]*list.List, slotNum)}
+		for i := 0; i < slotNum; i++ {
+			level.slots[i] = list.New()
+		}
+		htw.levels = append(htw.levels, level)
+		levelInterval *= time.Duration(slotNum)
+	}
+	htw.maxSpan = levelInterval
+
+	htw.pool = newJobPool(o.workers, o.workers*defaultQueueMultiplier, o.jobTimeout, o.onPanic, func(key interface{}) {
+		atomic.AddUint64(&htw.tasksDropped, 1)
+		if o.onOverflow != nil {
+			o.onOverflow(key)
+		}
+	})
+
+	return htw
+}
+
+// Start 启动多级时间轮。若配置了Storage, 会先加载其中保存的全部任务并重新调度
+func (htw *HierarchicalTimeWheel) Start() {
+	htw.restoreFromStorage()
+	htw.ticker = time.NewTicker(htw.tickInterval)
+	go htw.start()
+}
+
+// restoreFromStorage 从storage加载所有任务并重新插入轮盘, 找不到对应注册job的任务会被跳过
+func (htw *HierarchicalTimeWheel) restoreFromStorage() {
+	if htw.storage == nil {
+		return
+	}
+
+	stored, err := htw.storage.LoadAll()
+	if err != nil {
+		return
+	}
+
+	for _, st := range stored {
+		job, ok := lookupJob(st.JobName)
+		if !ok {
+			continue
+		}
+		// 剩余延迟超出本轮盘容量时locate会静默回绕到错误槽位, 这里宁可跳过也不要提前误触发
+		if remaining := time.Until(st.Deadline); remaining > htw.maxSpan {
+			continue
+		}
+
+		htw.addTask(&htask{interval: st.Interval, deadline: st.Deadline, times: st.Times, key: st.Key, job: job, taskData: st.Data, jobName: st.JobName})
+	}
+}
+
+// Stop 停止多级时间轮, 可安全地并发/重复调用
+func (htw *HierarchicalTimeWheel) Stop() {
+	htw.stopOnce.Do(func() {
+		htw.mu.Lock()
+		htw.stopped = true
+		htw.mu.Unlock()
+		htw.sendWG.Wait()
+		close(htw.stopChannel)
+		htw.pool.stop()
+	})
+}
+
+func (htw *HierarchicalTimeWheel) start() {
+	for {
+		select {
+		case <-htw.ticker.C:
+			htw.tick()
+		case t := <-htw.addTaskChannel:
+			htw.addTask(&t)
+		case update := <-htw.updateTaskChannel:
+			htw.updateTask(update)
+		case key := <-htw.removeTaskChannel:
+			htw.removeTask(key)
+		case <-htw.stopChannel:
+			htw.ticker.Stop()
+			return
+		}
+	}
+}
+
+// MaxDelay 返回该多级时间轮能够精确调度的最大延迟(各级槽位跨度的乘积)。
+// AddTask/AddNamedTask的interval超过该值会返回ErrDelayTooLarge, 而不是静默回绕到错误的槽位
+func (htw *HierarchicalTimeWheel) MaxDelay() time.Duration {
+	return htw.maxSpan
+}
+
+// reserveAndSend 为key预占pendingLevel占位, 登记一次在途发送(sendWG), 再把任务发送到
+// addTaskChannel。stopped判断和sendWG.Add必须在同一个mu临界区内完成, 这样Stop()的
+// "置stopped=true(持有mu) -> 释放mu -> sendWG.Wait()"序列就能保证: 每一次成功的Add都
+// 发生在stopped被置true之前, 因此必然先于之后的Wait调用完成, close(stopChannel)也就不会
+// 早于所有已放行的发送完成。不这样做的话, close(stopChannel)可能恰好发生在判断之后、
+// 发送之前, select会在"channel有空位"和"stopChannel已关闭"两个就绪分支间随机选择,
+// 导致任务悄悄进入一个consumer已经退出、永远不会再被消费的channel, 同时其pendingLevel
+// 占位永久卡住该key。发送本身必须在mu之外进行: addTaskChannel的consumer处理完一个任务
+// 同样要拿mu, 若这里发送时还攥着mu, buffer打满时consumer会因拿不到mu而无法腾出新的空位,
+// 造成死锁
+func (htw *HierarchicalTimeWheel) reserveAndSend(key interface{}, t htask) error {
+	htw.mu.Lock()
+	if htw.stopped {
+		htw.mu.Unlock()
+		return ErrStopped
+	}
+	if _, exists := htw.timer[key]; exists {
+		htw.mu.Unlock()
+		return ErrDuplicateKey
+	}
+	htw.timer[key] = hPosition{level: pendingLevel}
+	htw.sendWG.Add(1)
+	htw.mu.Unlock()
+
+	defer htw.sendWG.Done()
+	htw.addTaskChannel <- t
+	return nil
+}
+
+// AddTask 添加任务, interval 为首次触发的延迟。时间轮已停止时返回 ErrStopped,
+// key已存在时返回 ErrDuplicateKey, interval超出MaxDelay()时返回ErrDelayTooLarge
+func (htw *HierarchicalTimeWheel) AddTask(interval time.Duration, times int, key interface{}, data TaskData, job Job) error {
+	if interval <= 0 || key == nil || job == nil {
+		return ErrInvalidTask
+	}
+	if interval > htw.maxSpan {
+		return ErrDelayTooLarge
+	}
+
+	return htw.reserveAndSend(key, htask{interval: interval, deadline: time.Now().Add(interval), times: times, key: key, taskData: data, job: job})
+}
+
+// AddNamedTask 与AddTask相同, 但job必须已通过RegisterJob以jobName注册。
+// 当时间轮配置了Storage时, 只有通过AddNamedTask添加的任务才会被持久化,
+// 因为重启后只能凭jobName从注册表中找回回调函数。interval超出MaxDelay()时返回ErrDelayTooLarge
+func (htw *HierarchicalTimeWheel) AddNamedTask(interval time.Duration, times int, key interface{}, data TaskData, jobName string) error {
+	job, ok := lookupJob(jobName)
+	if !ok {
+		return fmt.Errorf("timewheel: job %q is not registered", jobName)
+	}
+	if interval <= 0 || key == nil {
+		return ErrInvalidTask
+	}
+	if interval > htw.maxSpan {
+		return ErrDelayTooLarge
+	}
+
+	return htw.reserveAndSend(key, htask{interval: interval, deadline: time.Now().Add(interval), times: times, key: key, taskData: data, job: job, jobName: jobName})
+}
+
+// UpdateTask 更新任务的执行间隔和参数。时间轮已停止时返回 ErrStopped,
+// interval超出MaxDelay()时返回ErrDelayTooLarge, 防止静默回绕到错误的槽位
+func (htw *HierarchicalTimeWheel) UpdateTask(key interface{}, interval time.Duration, data TaskData) error {
+	if key == nil {
+		return ErrInvalidKey
+	}
+	if interval <= 0 {
+		return ErrInvalidTask
+	}
+	if interval > htw.maxSpan {
+		return ErrDelayTooLarge
+	}
+
+	htw.mu.Lock()
+	if htw.stopped {
+		htw.mu.Unlock()
+		return ErrStopped
+	}
+	htw.sendWG.Add(1)
+	htw.mu.Unlock()
+
+	defer htw.sendWG.Done()
+	htw.updateTaskChannel <- uptask{key: key, interval: interval, taskData: data}
+	return nil
+}
+
+// RemoveTask 移除任务。时间轮已停止时返回 ErrStopped
+func (htw *HierarchicalTimeWheel) RemoveTask(key interface{}) error {
+	if key == nil {
+		return ErrInvalidKey
+	}
+
+	htw.mu.Lock()
+	if htw.stopped {
+		htw.mu.Unlock()
+		return ErrStopped
+	}
+	htw.sendWG.Add(1)
+	htw.mu.Unlock()
+
+	defer htw.sendWG.Done()
+	htw.removeTaskChannel <- key
+	return nil
+}
+
+// tick 推进第0级(最细粒度)指针一格
+func (htw *HierarchicalTimeWheel) tick() {
+	htw.recordTick()
+	htw.advance(0)
+}
+
+// recordTick 记录本次tick相对上一次tick+tickInterval的滞后时间, 供Stats()上报tick-lag
+func (htw *HierarchicalTimeWheel) recordTick() {
+	now := time.Now()
+
+	htw.statsMu.Lock()
+	defer htw.statsMu.Unlock()
+
+	if !htw.lastTick.IsZero() {
+		htw.tickLag = now.Sub(htw.lastTick.Add(htw.tickInterval))
+	}
+	htw.lastTick = now
+}
+
+// recordJobDuration 记录一次job执行耗时, 超出maxJobDurationSamples时丢弃最旧的采样
+func (htw *HierarchicalTimeWheel) recordJobDuration(d time.Duration) {
+	htw.statsMu.Lock()
+	defer htw.statsMu.Unlock()
+
+	htw.jobDurations = append(htw.jobDurations, d)
+	if len(htw.jobDurations) > maxJobDurationSamples {
+		htw.jobDurations = htw.jobDurations[len(htw.jobDurations)-maxJobDurationSamples:]
+	}
+}
+
+// advance 推进第 level 级指针一格; 第0级到期任务直接执行, 其余级别满一圈后级联降级
+func (htw *HierarchicalTimeWheel) advance(level int) {
+	lv := htw.levels[level]
+	l := lv.slots[lv.currentPos]
+	if level == 0 {
+		htw.scanAddRunTask(l)
+	} else {
+		htw.cascade(l)
+	}
+
+	if lv.currentPos == lv.slotNum-1 {
+		lv.currentPos = 0
+		if level+1 < len(htw.levels) {
+			htw.advance(level + 1)
+		}
+	} else {
+		lv.currentPos++
+	}
+}
+
+// cascade 将上级指针走过的槽中的任务按剩余时间重新分配到合适的级别和槽位
+func (htw *HierarchicalTimeWheel) cascade(l *list.List) {
+	htw.mu.Lock()
+	defer htw.mu.Unlock()
+
+	for e := l.Front(); e != nil; {
+		t := e.Value.(*htask)
+		next := e.Next()
+		l.Remove(e)
+		e = next
+
+		htw.addTaskLocked(t)
+	}
+}
+
+// addTask 根据任务剩余延迟选择能容纳它的最细粒度级别, 插入对应槽位
+func (htw *HierarchicalTimeWheel) addTask(t *htask) {
+	htw.mu.Lock()
+	defer htw.mu.Unlock()
+	htw.addTaskLocked(t)
+}
+
+// addTaskLocked 添加任务, 调用方需已持有htw.mu
+func (htw *HierarchicalTimeWheel) addTaskLocked(t *htask) {
+	htw.insertLocked(t, false)
+}
+
+// insertLocked 按剩余时间定位并插入任务, 调用方需已持有htw.mu。avoidCurrentSlot为true时,
+// 若算出的目标恰好是level0当前正在处理的槽位, 会强制挪到下一格。这只在scanAddRunTask内
+// 周期任务触发后立即重新入轮时需要: 此时level0.currentPos要等scanAddRunTask返回后
+// advance()才会推进, 若remaining小于level0.interval导致locate()又把任务塞回这个槽位,
+// 它就要等整整一圈(slotNum*tickInterval)之后才会被再次扫到, 而不是下一次tick,
+// 表现为周期间隔小于level0.interval的任务实际触发周期被拉长成了slotNum*tickInterval
+func (htw *HierarchicalTimeWheel) insertLocked(t *htask, avoidCurrentSlot bool) {
+	remaining := time.Until(t.deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	level, pos := htw.locate(remaining)
+	if avoidCurrentSlot && level == 0 && pos == htw.levels[0].currentPos {
+		pos = (pos + 1) % htw.levels[0].slotNum
+	}
+	htw.levels[level].slots[pos].PushBack(t)
+	htw.timer[t.key] = hPosition{level: level, pos: pos}
+
+	htw.persist(t)
+}
+
+// persist 将任务镜像到storage。只有通过AddNamedTask添加、带有jobName的任务才会被持久化,
+// 因为匿名job函数值在进程重启后无法找回
+func (htw *HierarchicalTimeWheel) persist(t *htask) {
+	if htw.storage == nil || t.jobName == "" {
+		return
+	}
+	_ = htw.storage.Save(StoredTask{
+		Key:      t.key,
+		JobName:  t.jobName,
+		Data:     t.taskData,
+		Deadline: t.deadline,
+		Interval: t.interval,
+		Times:    t.times,
+	})
+}
+
+// unpersist 从storage中移除任务
+func (htw *HierarchicalTimeWheel) unpersist(key interface{}) {
+	if htw.storage == nil {
+		return
+	}
+	_ = htw.storage.Delete(key)
+}
+
+// locate 选择能容纳 remaining 延迟的最高精度级别, 返回级别下标和槽位
+func (htw *HierarchicalTimeWheel) locate(remaining time.Duration) (level int, pos int) {
+	for i, lv := range htw.levels {
+		span := lv.interval * time.Duration(lv.slotNum)
+		if remaining < span || i == len(htw.levels)-1 {
+			steps := int(remaining / lv.interval)
+			pos = (lv.currentPos + steps) % lv.slotNum
+			return i, pos
+		}
+	}
+	return 0, htw.levels[0].currentPos
+}
+
+// updateTask 更新任务参数, 按新的剩余时间重新定位所在级别和槽位
+func (htw *HierarchicalTimeWheel) updateTask(update uptask) {
+	htw.mu.Lock()
+	defer htw.mu.Unlock()
+
+	pos, ok := htw.timer[update.key]
+	if !ok || pos.level == pendingLevel {
+		return
+	}
+
+	l := htw.levels[pos.level].slots[pos.pos]
+	for e := l.Front(); e != nil; e = e.Next() {
+		t := e.Value.(*htask)
+		if t.key != update.key {
+			continue
+		}
+
+		t.interval = update.interval
+		t.taskData = update.taskData
+		t.deadline = time.Now().Add(update.interval)
+
+		l.Remove(e)
+		htw.addTaskLocked(t)
+		return
+	}
+}
+
+// removeTask 移除任务
+func (htw *HierarchicalTimeWheel) removeTask(key interface{}) {
+	htw.mu.Lock()
+	defer htw.mu.Unlock()
+
+	pos, ok := htw.timer[key]
+	if !ok || pos.level == pendingLevel {
+		return
+	}
+
+	l := htw.levels[pos.level].slots[pos.pos]
+	for e := l.Front(); e != nil; e = e.Next() {
+		t := e.Value.(*htask)
+		if t.key == key {
+			delete(htw.timer, key)
+			l.Remove(e)
+			htw.unpersist(key)
+			return
+		}
+	}
+}
+
+// scanAddRunTask 扫描第0级到期槽中的任务并执行回调函数, 周期任务重新计算下一次到期时间后重新添加
+func (htw *HierarchicalTimeWheel) scanAddRunTask(l *list.List) {
+	if l == nil {
+		return
+	}
+
+	htw.mu.Lock()
+	defer htw.mu.Unlock()
+
+	for item := l.Front(); item != nil; {
+		t := item.Value.(*htask)
+		next := item.Next()
+		l.Remove(item)
+		delete(htw.timer, t.key)
+		htw.unpersist(t.key)
+		item = next
+
+		if t.times == 0 {
+			continue
+		}
+
+		if htw.pool.submit(firedJob{key: t.key, job: t.job, taskData: t.taskData, done: htw.recordJobDuration}) {
+			atomic.AddUint64(&htw.tasksFired, 1)
+		}
+
+		if t.times > 0 || t.times == -1 {
+			if t.times > 0 {
+				t.times--
+			}
+			if t.times != 0 {
+				t.deadline = time.Now().Add(t.interval)
+				htw.insertLocked(t, true)
+			}
+		}
+	}
+}
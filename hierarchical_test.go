@@ -0,0 +1,122 @@
+package timewheel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHierarchicalLocate 验证locate()为不同剩余延迟选择的级别和相对当前指针的步数,
+// 覆盖"恰好等于某级跨度"的边界以及"超出所有级别跨度, 落在最后一级并回绕"的情形
+func TestHierarchicalLocate(t *testing.T) {
+	htw := NewHierarchical(10*time.Millisecond, []int{4, 3}) // level0: 10ms*4=40ms跨度, level1: 40ms*3=120ms跨度
+	if htw == nil {
+		t.Fatal("NewHierarchical returned nil")
+	}
+
+	cases := []struct {
+		name      string
+		remaining time.Duration
+		wantLevel int
+		wantSteps int // 相对该级当前指针的步数
+	}{
+		{"zero", 0, 0, 0},
+		{"within level0", 25 * time.Millisecond, 0, 2},
+		{"last slot of level0", 39 * time.Millisecond, 0, 3},
+		{"exactly level0 span spills to level1", 40 * time.Millisecond, 1, 1},
+		{"within level1", 119 * time.Millisecond, 1, 2},
+		{"beyond maxSpan wraps on last level", 500 * time.Millisecond, 1, 0}, // 500/40=12, 12%3=0
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			level, pos := htw.locate(c.remaining)
+			if level != c.wantLevel {
+				t.Fatalf("locate(%v) level = %d, want %d", c.remaining, level, c.wantLevel)
+			}
+			lv := htw.levels[level]
+			steps := (pos - lv.currentPos + lv.slotNum) % lv.slotNum
+			if steps != c.wantSteps {
+				t.Fatalf("locate(%v) steps = %d, want %d", c.remaining, steps, c.wantSteps)
+			}
+		})
+	}
+}
+
+// TestHierarchicalCascade 验证超出level0跨度的任务会先落在上级, 再随advance()级联降级,
+// 最终在接近原定延迟的时刻触发, 而不是提前(停留在上级里被跳过)或永久丢失
+func TestHierarchicalCascade(t *testing.T) {
+	htw := NewHierarchical(5*time.Millisecond, []int{4, 4}) // level0跨度20ms, level1跨度80ms
+	htw.Start()
+	defer htw.Stop()
+
+	fired := make(chan struct{}, 1)
+	start := time.Now()
+	if err := htw.AddTask(50*time.Millisecond, 1, "cascade-key", nil, func(TaskData) {
+		fired <- struct{}{}
+	}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	select {
+	case <-fired:
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > 200*time.Millisecond {
+			t.Fatalf("job fired after %v, want ~50ms", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("job never fired, cascade is broken")
+	}
+}
+
+// TestHierarchicalPeriodicTaskFasterThanLevel0Interval 回归测试: 周期任务(times=-1或>1)
+// 的interval小于level0.interval时, scanAddRunTask触发后把它重新插回轮盘必须避开当前
+// 正在扫描、即将离开的槽位, 否则要等上整整一圈(slotNum*tickInterval)才会被再次扫到,
+// 实际触发周期被拉长成slotNum*tickInterval而不是配置的interval
+func TestHierarchicalPeriodicTaskFasterThanLevel0Interval(t *testing.T) {
+	htw := NewHierarchical(20*time.Millisecond, []int{10, 6}) // level0跨度200ms
+	htw.Start()
+	defer htw.Stop()
+
+	var fires int64
+	if err := htw.AddTask(10*time.Millisecond, -1, "fast-periodic", nil, func(TaskData) {
+		atomic.AddInt64(&fires, 1)
+	}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	// 每次触发后重新落到下一格, 按tick(20ms)触发, 150ms内应有7次左右; 退化成按slotNum*tickInterval(=200ms)触发的话,
+	// 这段时间里顶多只能看到最初那一次
+	if got := atomic.LoadInt64(&fires); got < 5 {
+		t.Fatalf("fired %d times in 150ms, want at least 5 (job period is 10ms)", got)
+	}
+}
+
+// TestHierarchicalAddTaskRejectsOversizedDelay 回归测试: interval超出MaxDelay()时
+// AddTask必须返回ErrDelayTooLarge, 而不是静默回绕到错误的槽位
+func TestHierarchicalAddTaskRejectsOversizedDelay(t *testing.T) {
+	htw := NewHierarchical(10*time.Millisecond, []int{5}) // maxSpan=50ms
+	if err := htw.AddTask(time.Second, 1, "k", nil, func(TaskData) {}); err != ErrDelayTooLarge {
+		t.Fatalf("AddTask with oversized delay = %v, want ErrDelayTooLarge", err)
+	}
+}
+
+// TestHierarchicalUpdateTaskRejectsOversizedDelay 回归测试: UpdateTask此前没有校验
+// interval是否超出MaxDelay(), 会重新把任务回绕到错误的槽位并提前触发(见chunk0-1),
+// 这里同时覆盖interval<=0的情形
+func TestHierarchicalUpdateTaskRejectsOversizedDelay(t *testing.T) {
+	htw := NewHierarchical(10*time.Millisecond, []int{5}) // maxSpan=50ms
+	htw.Start()
+	defer htw.Stop()
+
+	if err := htw.AddTask(20*time.Millisecond, 1, "k", nil, func(TaskData) {}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	if err := htw.UpdateTask("k", 10*time.Second, nil); err != ErrDelayTooLarge {
+		t.Fatalf("UpdateTask with oversized delay = %v, want ErrDelayTooLarge", err)
+	}
+	if err := htw.UpdateTask("k", 0, nil); err != ErrInvalidTask {
+		t.Fatalf("UpdateTask with non-positive delay = %v, want ErrInvalidTask", err)
+	}
+}
@@ -0,0 +1,75 @@
+package timewheel
+
+import "sync/atomic"
+
+// Stats 返回多级时间轮当前的运行状态快照。SlotHistogram按级别展平, 下标顺序为
+// level0的全部槽位, 然后是level1的全部槽位, 以此类推
+func (htw *HierarchicalTimeWheel) Stats() Stats {
+	htw.mu.RLock()
+	var histogram []int
+	pending := 0
+	slotNum := 0
+	for _, lv := range htw.levels {
+		slotNum += lv.slotNum
+		for _, l := range lv.slots {
+			histogram = append(histogram, l.Len())
+			pending += l.Len()
+		}
+	}
+	htw.mu.RUnlock()
+
+	htw.statsMu.Lock()
+	tickLag := htw.tickLag
+	mean, p95 := jobRuntimeStats(htw.jobDurations)
+	htw.statsMu.Unlock()
+
+	return Stats{
+		SlotNum:        slotNum,
+		PendingTasks:   pending,
+		SlotHistogram:  histogram,
+		TasksFired:     atomic.LoadUint64(&htw.tasksFired),
+		TasksDropped:   atomic.LoadUint64(&htw.tasksDropped),
+		TickLag:        tickLag,
+		MeanJobRuntime: mean,
+		P95JobRuntime:  p95,
+	}
+}
+
+// List 返回当前所有已调度任务的只读信息
+func (htw *HierarchicalTimeWheel) List() []TaskInfo {
+	htw.mu.RLock()
+	defer htw.mu.RUnlock()
+
+	out := make([]TaskInfo, 0, len(htw.timer))
+	for _, lv := range htw.levels {
+		for _, l := range lv.slots {
+			for e := l.Front(); e != nil; e = e.Next() {
+				out = append(out, hTaskInfo(e.Value.(*htask)))
+			}
+		}
+	}
+	return out
+}
+
+// Get 返回key对应任务的只读信息, 若不存在则ok为false
+func (htw *HierarchicalTimeWheel) Get(key interface{}) (TaskInfo, bool) {
+	htw.mu.RLock()
+	defer htw.mu.RUnlock()
+
+	pos, ok := htw.timer[key]
+	if !ok || pos.level == pendingLevel {
+		return TaskInfo{}, false
+	}
+
+	l := htw.levels[pos.level].slots[pos.pos]
+	for e := l.Front(); e != nil; e = e.Next() {
+		if t := e.Value.(*htask); t.key == key {
+			return hTaskInfo(t), true
+		}
+	}
+	return TaskInfo{}, false
+}
+
+func hTaskInfo(t *htask) TaskInfo {
+	return TaskInfo{Key: t.key, JobName: t.jobName, Times: t.times, NextFire: t.deadline}
+}
@@ -0,0 +1,76 @@
+package timewheel
+
+import (
+	"runtime"
+	"time"
+)
+
+// defaultChanSize 任务操作channel的默认缓冲区大小
+const defaultChanSize = 16
+
+// defaultQueueMultiplier 默认的job触发队列大小相对worker数量的倍数
+const defaultQueueMultiplier = 4
+
+// wheelOptions TimeWheel/HierarchicalTimeWheel 公共配置
+type wheelOptions struct {
+	chanSize   int
+	storage    Storage
+	workers    int
+	jobTimeout time.Duration
+	onPanic    func(key interface{}, r interface{}, stack []byte)
+	onOverflow func(key interface{})
+}
+
+func defaultWheelOptions() wheelOptions {
+	return wheelOptions{
+		chanSize: defaultChanSize,
+		workers:  runtime.NumCPU() * 2,
+	}
+}
+
+// Option 用于配置 TimeWheel / HierarchicalTimeWheel
+type Option func(*wheelOptions)
+
+// WithChannelSize 设置 AddTask/UpdateTask/RemoveTask 对应channel的缓冲区大小。
+// 缓冲区越大, 在Job回调里同步调用这些方法时越不容易因channel阻塞而死锁
+func WithChannelSize(size int) Option {
+	return func(o *wheelOptions) {
+		if size > 0 {
+			o.chanSize = size
+		}
+	}
+}
+
+// WithWorkers 设置执行到期任务的worker池大小, 默认为 runtime.NumCPU()*2。
+// 这限制了同时运行的job goroutine数量, 避免高负载下无限制地 go task.job(...)
+func WithWorkers(n int) Option {
+	return func(o *wheelOptions) {
+		if n > 0 {
+			o.workers = n
+		}
+	}
+}
+
+// WithJobTimeout 设置单次job执行的超时时间。超时后worker不再等待该job返回,
+// 转而处理下一个任务; job可以从TaskData中读取JobContextKey对应的context.Context,
+// 在其Done()时主动退出以配合真正取消, 不读取该context的job不受影响。默认不设置超时
+func WithJobTimeout(d time.Duration) Option {
+	return func(o *wheelOptions) {
+		o.jobTimeout = d
+	}
+}
+
+// WithOnPanic 设置job执行过程中发生panic时的处理函数, 用于替代"整个进程崩溃"
+func WithOnPanic(fn func(key interface{}, r interface{}, stack []byte)) Option {
+	return func(o *wheelOptions) {
+		o.onPanic = fn
+	}
+}
+
+// WithOnOverflow 设置worker池队列已满、任务被丢弃时的处理函数,
+// 调用方可以在其中选择记录日志、重新调度或直接忽略
+func WithOnOverflow(fn func(key interface{})) Option {
+	return func(o *wheelOptions) {
+		o.onOverflow = fn
+	}
+}
@@ -0,0 +1,139 @@
+package timewheel
+
+import (
+	"context"
+	"runtime/debug"
+	"time"
+)
+
+// firedJob 是一次到期任务触发后提交给worker池执行的工作单元
+type firedJob struct {
+	key      interface{}
+	job      Job
+	taskData TaskData
+	done     func(time.Duration) //执行完成(或超时放弃等待)后回调, 用于记录耗时统计
+}
+
+// jobContextKey 是WithJobTimeout关联的context.Context在TaskData中存放的键的类型,
+// 未导出以避免与调用方自己往TaskData里塞的key冲突
+type jobContextKey struct{}
+
+// JobContextKey 配置了WithJobTimeout时, job可以通过 data[timewheel.JobContextKey].(context.Context)
+// 取出本次执行关联的context, 并在ctx.Done()时提前返回。不读取该context的job不受影响:
+// 超时到达后worker只是不再等待它返回, 转而处理下一个任务, 已发起的job goroutine仍会运行至结束
+var JobContextKey interface{} = jobContextKey{}
+
+// StripJobContext 返回一份不含JobContextKey的TaskData浅拷贝, 不含该key时原样返回d。
+// 像AddCron这样把job收到的TaskData原封转发给下一次调度的场景必须先调用它, 否则本次
+// 执行关联的(已取消的)context会被当作普通数据一起持久化到下一个task里
+func StripJobContext(d TaskData) TaskData {
+	if _, ok := d[JobContextKey]; !ok {
+		return d
+	}
+
+	out := make(TaskData, len(d)-1)
+	for k, v := range d {
+		if k == JobContextKey {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// jobPool 有界worker池, 负责实际执行到期任务的回调。
+// 相比直接 go task.job(...), 它能限制并发goroutine数量, 并统一处理panic和超时
+type jobPool struct {
+	queue      chan firedJob
+	jobTimeout time.Duration
+	onPanic    func(key interface{}, r interface{}, stack []byte)
+	onOverflow func(key interface{})
+}
+
+// newJobPool 创建并启动一个worker池, workers个worker持续消费queue
+func newJobPool(workers, queueSize int, jobTimeout time.Duration, onPanic func(interface{}, interface{}, []byte), onOverflow func(interface{})) *jobPool {
+	p := &jobPool{
+		queue:      make(chan firedJob, queueSize),
+		jobTimeout: jobTimeout,
+		onPanic:    onPanic,
+		onOverflow: onOverflow,
+	}
+
+	for i := 0; i < workers; i++ {
+		go p.worker()
+	}
+
+	return p
+}
+
+// stop 关闭任务队列, 所有worker在处理完队列中剩余任务后退出
+func (p *jobPool) stop() {
+	close(p.queue)
+}
+
+// submit 尝试把一次任务触发提交给worker池, 队列已满时调用onOverflow(若配置)并丢弃该任务
+func (p *jobPool) submit(fj firedJob) (accepted bool) {
+	select {
+	case p.queue <- fj:
+		return true
+	default:
+		if p.onOverflow != nil {
+			p.onOverflow(fj.key)
+		}
+		return false
+	}
+}
+
+func (p *jobPool) worker() {
+	for fj := range p.queue {
+		p.run(fj)
+	}
+}
+
+// run 执行单个job, 恢复其中的panic, 并在配置了jobTimeout时不再无限等待超时的job返回
+func (p *jobPool) run(fj firedJob) {
+	start := time.Now()
+	finished := make(chan struct{})
+	data := fj.taskData
+
+	var ctx context.Context
+	if p.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), p.jobTimeout)
+		defer cancel()
+
+		// 浅拷贝一份TaskData带上ctx, 避免污染原始task.taskData(它可能还会被持久化/List读取);
+		// job若想在超时时提前退出, 可取出JobContextKey并监听ctx.Done()
+		data = make(TaskData, len(fj.taskData)+1)
+		for k, v := range fj.taskData {
+			data[k] = v
+		}
+		data[JobContextKey] = ctx
+	}
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil && p.onPanic != nil {
+				p.onPanic(fj.key, r, debug.Stack())
+			}
+			close(finished)
+		}()
+		fj.job(data)
+	}()
+
+	if p.jobTimeout > 0 {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			// 超过jobTimeout仍未返回: worker不再等待, 转而处理队列里的下一个任务。
+			// ctx已被cancel, 若job有读取JobContextKey则能借此尽快退出; 否则它仍会
+			// 运行至真正结束或panic, 并自行通过onPanic完成收尾
+		}
+	} else {
+		<-finished
+	}
+
+	if fj.done != nil {
+		fj.done(time.Since(start))
+	}
+}
@@ -0,0 +1,159 @@
+package timewheel
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestJobPoolRecoversPanic 验证worker执行job时发生panic会被恢复, 并通过onPanic上报key
+// 和recover()的值, 而不是让整个进程崩溃
+func TestJobPoolRecoversPanic(t *testing.T) {
+	var gotKey interface{}
+	var gotR interface{}
+	done := make(chan struct{})
+
+	p := newJobPool(1, 1, 0, func(key interface{}, r interface{}, stack []byte) {
+		gotKey, gotR = key, r
+		close(done)
+	}, nil)
+	defer p.stop()
+
+	if !p.submit(firedJob{key: "boom", job: func(TaskData) { panic("kaboom") }}) {
+		t.Fatal("submit returned false, want true")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("onPanic was never called")
+	}
+
+	if gotKey != "boom" {
+		t.Fatalf("onPanic key = %v, want %q", gotKey, "boom")
+	}
+	if gotR != "kaboom" {
+		t.Fatalf("onPanic r = %v, want %q", gotR, "kaboom")
+	}
+
+	// worker本身必须在panic恢复后继续工作, 而不是把自己也搭进去
+	ran := make(chan struct{})
+	if !p.submit(firedJob{key: "after", job: func(TaskData) { close(ran) }}) {
+		t.Fatal("submit after panic returned false, want true")
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not process jobs after recovering from a panic")
+	}
+}
+
+// TestJobPoolOnOverflow 验证队列打满后submit返回false并调用onOverflow(key),
+// 而不是无限阻塞或悄悄丢弃
+func TestJobPoolOnOverflow(t *testing.T) {
+	block := make(chan struct{})
+	var overflowKey interface{}
+	var overflowCalls int32
+
+	// queueSize=0: channel没有缓冲区, 唯一的worker一旦在处理某个job就再也腾不出
+	// 空位, 下一次submit必然立即命中default分支
+	p := newJobPool(1, 0, 0, nil, func(key interface{}) {
+		atomic.AddInt32(&overflowCalls, 1)
+		overflowKey = key
+	})
+	defer func() {
+		close(block)
+		p.stop()
+	}()
+	time.Sleep(20 * time.Millisecond) // 等worker goroutine真正启动并准备好接收
+
+	// 占满唯一的worker, 让它卡在block上不返回
+	if !p.submit(firedJob{key: "busy", job: func(TaskData) { <-block }}) {
+		t.Fatal("submit(busy) returned false, want true")
+	}
+	// worker仍忙于上面那个job, 给它一点时间真正进入阻塞状态, 确保下一次submit
+	// 是命中"channel没有空位"的default分支, 而不是偶然也被同一个worker接走
+	time.Sleep(20 * time.Millisecond)
+
+	if accepted := p.submit(firedJob{key: "overflow"}); accepted {
+		t.Fatal("submit returned true, want false once queue is full")
+	}
+
+	if atomic.LoadInt32(&overflowCalls) != 1 {
+		t.Fatalf("onOverflow called %d times, want 1", overflowCalls)
+	}
+	if overflowKey != "overflow" {
+		t.Fatalf("onOverflow key = %v, want %q", overflowKey, "overflow")
+	}
+}
+
+// TestJobPoolJobTimeout 验证配置了jobTimeout后, worker不会因为一个迟迟不返回的job
+// 而卡住整条队列: 它在超时后继续处理下一个任务, 即便慢job本身仍在后台运行
+func TestJobPoolJobTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	p := newJobPool(1, 2, 30*time.Millisecond, nil, nil)
+	defer p.stop()
+
+	start := time.Now()
+	if !p.submit(firedJob{key: "slow-1", job: func(TaskData) {
+		defer wg.Done()
+		time.Sleep(300 * time.Millisecond)
+	}}) {
+		t.Fatal("submit(slow-1) returned false, want true")
+	}
+	if !p.submit(firedJob{key: "slow-2", job: func(TaskData) {
+		defer wg.Done()
+		time.Sleep(300 * time.Millisecond)
+	}}) {
+		t.Fatal("submit(slow-2) returned false, want true")
+	}
+
+	// worker不等待job真正返回, 两个任务背靠背超时后就该被先后"放行", 远早于
+	// 两个300ms sleep串行执行完所需的600ms
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("jobs took %v to finish running in the background, want under 500ms (running them one after the other takes 600ms)", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed-out jobs never finished running in the background")
+	}
+}
+
+// TestJobPoolJobContextCancelledOnTimeout 验证job若读取JobContextKey, 能在超时后
+// 借助ctx.Done()提前退出, 而不必等到自己原本的逻辑跑完
+func TestJobPoolJobContextCancelledOnTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+
+	p := newJobPool(1, 1, 20*time.Millisecond, nil, nil)
+	defer p.stop()
+
+	if !p.submit(firedJob{key: "ctx-aware", job: func(d TaskData) {
+		ctx, _ := d[JobContextKey].(interface{ Done() <-chan struct{} })
+		if ctx == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			close(cancelled)
+		case <-time.After(time.Second):
+		}
+	}}) {
+		t.Fatal("submit returned false, want true")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("job's context was never cancelled after jobTimeout elapsed")
+	}
+}
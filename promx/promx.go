@@ -0,0 +1,70 @@
+// Package promx 将 timewheel.Stats 适配为 prometheus.Collector,
+// 用法: prometheus.MustRegister(promx.New(tw))
+package promx
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/yuud/timewheel"
+)
+
+// Stater 是被Collector包装的对象需要实现的接口,
+// *timewheel.TimeWheel 和 *timewheel.HierarchicalTimeWheel 都满足该接口
+type Stater interface {
+	Stats() timewheel.Stats
+}
+
+// Collector 将一个时间轮的运行状态以Prometheus指标的形式暴露出来
+type Collector struct {
+	tw Stater
+
+	pendingTasks   *prometheus.Desc
+	tasksFired     *prometheus.Desc
+	tasksDropped   *prometheus.Desc
+	tickLagSeconds *prometheus.Desc
+	meanJobRuntime *prometheus.Desc
+	p95JobRuntime  *prometheus.Desc
+	slotTasks      *prometheus.Desc
+}
+
+// New 创建一个包装tw的prometheus.Collector
+func New(tw Stater) *Collector {
+	return &Collector{
+		tw:             tw,
+		pendingTasks:   prometheus.NewDesc("timewheel_pending_tasks", "当前挂载的任务总数", nil, nil),
+		tasksFired:     prometheus.NewDesc("timewheel_tasks_fired_total", "累计已触发的任务数", nil, nil),
+		tasksDropped:   prometheus.NewDesc("timewheel_tasks_dropped_total", "累计因过载等原因被丢弃的任务数", nil, nil),
+		tickLagSeconds: prometheus.NewDesc("timewheel_tick_lag_seconds", "最近一次tick相对期望触发时间的滞后(秒)", nil, nil),
+		meanJobRuntime: prometheus.NewDesc("timewheel_job_runtime_mean_seconds", "job执行耗时均值(秒)", nil, nil),
+		p95JobRuntime:  prometheus.NewDesc("timewheel_job_runtime_p95_seconds", "job执行耗时95分位(秒)", nil, nil),
+		slotTasks:      prometheus.NewDesc("timewheel_slot_tasks", "每个槽位当前挂载的任务数", []string{"slot"}, nil),
+	}
+}
+
+// Describe 实现 prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pendingTasks
+	ch <- c.tasksFired
+	ch <- c.tasksDropped
+	ch <- c.tickLagSeconds
+	ch <- c.meanJobRuntime
+	ch <- c.p95JobRuntime
+	ch <- c.slotTasks
+}
+
+// Collect 实现 prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.tw.Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.pendingTasks, prometheus.GaugeValue, float64(stats.PendingTasks))
+	ch <- prometheus.MustNewConstMetric(c.tasksFired, prometheus.CounterValue, float64(stats.TasksFired))
+	ch <- prometheus.MustNewConstMetric(c.tasksDropped, prometheus.CounterValue, float64(stats.TasksDropped))
+	ch <- prometheus.MustNewConstMetric(c.tickLagSeconds, prometheus.GaugeValue, stats.TickLag.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.meanJobRuntime, prometheus.GaugeValue, stats.MeanJobRuntime.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.p95JobRuntime, prometheus.GaugeValue, stats.P95JobRuntime.Seconds())
+
+	for i, n := range stats.SlotHistogram {
+		ch <- prometheus.MustNewConstMetric(c.slotTasks, prometheus.GaugeValue, float64(n), strconv.Itoa(i))
+	}
+}
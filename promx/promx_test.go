@@ -0,0 +1,57 @@
+package promx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/yuud/timewheel"
+)
+
+// fakeStater 是一个最小的Stater实现, 用固定的Stats快照驱动Collector,
+// 避免测试依赖真实的TimeWheel/HierarchicalTimeWheel的调度时序
+type fakeStater struct {
+	stats timewheel.Stats
+}
+
+func (f fakeStater) Stats() timewheel.Stats {
+	return f.stats
+}
+
+// TestCollectorExposesStats 验证Collector.Collect()把Stats()的字段正确映射成了
+// 对应的Prometheus指标, 包括按slot展开的timewheel_slot_tasks
+func TestCollectorExposesStats(t *testing.T) {
+	c := New(fakeStater{stats: timewheel.Stats{
+		PendingTasks:  3,
+		TasksFired:    10,
+		TasksDropped:  2,
+		SlotHistogram: []int{1, 0, 2},
+	}})
+
+	const want = `
+# HELP timewheel_pending_tasks 当前挂载的任务总数
+# TYPE timewheel_pending_tasks gauge
+timewheel_pending_tasks 3
+# HELP timewheel_tasks_fired_total 累计已触发的任务数
+# TYPE timewheel_tasks_fired_total counter
+timewheel_tasks_fired_total 10
+# HELP timewheel_tasks_dropped_total 累计因过载等原因被丢弃的任务数
+# TYPE timewheel_tasks_dropped_total counter
+timewheel_tasks_dropped_total 2
+# HELP timewheel_slot_tasks 每个槽位当前挂载的任务数
+# TYPE timewheel_slot_tasks gauge
+timewheel_slot_tasks{slot="0"} 1
+timewheel_slot_tasks{slot="1"} 0
+timewheel_slot_tasks{slot="2"} 2
+`
+
+	names := []string{
+		"timewheel_pending_tasks",
+		"timewheel_tasks_fired_total",
+		"timewheel_tasks_dropped_total",
+		"timewheel_slot_tasks",
+	}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want), names...); err != nil {
+		t.Fatalf("unexpected collected metrics: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package timewheel
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// TaskInfo 对外暴露的任务只读信息快照, 由List()/Get()返回
+type TaskInfo struct {
+	Key      interface{}
+	JobName  string
+	Times    int
+	NextFire time.Time
+}
+
+// Stats 时间轮运行状态快照, 由Stats()返回
+type Stats struct {
+	SlotNum        int           //槽位总数
+	PendingTasks   int           //当前挂载的任务总数
+	SlotHistogram  []int         //每个槽位当前挂载的任务数, 下标即槽位编号
+	TasksFired     uint64        //累计已触发的任务数
+	TasksDropped   uint64        //累计因过载等原因被丢弃的任务数
+	TickLag        time.Duration //最近一次tick相对期望触发时间的滞后
+	MeanJobRuntime time.Duration //job执行耗时的均值(基于最近的采样)
+	P95JobRuntime  time.Duration //job执行耗时的95分位(基于最近的采样)
+}
+
+// Stats 返回时间轮当前的运行状态快照
+func (tw *TimeWheel) Stats() Stats {
+	tw.mu.RLock()
+	histogram := make([]int, tw.slotNum)
+	pending := 0
+	for i, l := range tw.slots {
+		histogram[i] = l.Len()
+		pending += l.Len()
+	}
+	tw.mu.RUnlock()
+
+	tw.statsMu.Lock()
+	tickLag := tw.tickLag
+	mean, p95 := jobRuntimeStats(tw.jobDurations)
+	tw.statsMu.Unlock()
+
+	return Stats{
+		SlotNum:        tw.slotNum,
+		PendingTasks:   pending,
+		SlotHistogram:  histogram,
+		TasksFired:     atomic.LoadUint64(&tw.tasksFired),
+		TasksDropped:   atomic.LoadUint64(&tw.tasksDropped),
+		TickLag:        tickLag,
+		MeanJobRuntime: mean,
+		P95JobRuntime:  p95,
+	}
+}
+
+// List 返回当前所有已调度任务的只读信息
+func (tw *TimeWheel) List() []TaskInfo {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	out := make([]TaskInfo, 0, len(tw.timer))
+	for _, l := range tw.slots {
+		for e := l.Front(); e != nil; e = e.Next() {
+			out = append(out, taskInfo(e.Value.(*task)))
+		}
+	}
+	return out
+}
+
+// Get 返回key对应任务的只读信息, 若不存在则ok为false
+func (tw *TimeWheel) Get(key interface{}) (TaskInfo, bool) {
+	tw.mu.RLock()
+	defer tw.mu.RUnlock()
+
+	pos, ok := tw.timer[key]
+	if !ok || pos == pendingPos {
+		return TaskInfo{}, false
+	}
+
+	l := tw.slots[pos]
+	for e := l.Front(); e != nil; e = e.Next() {
+		if t := e.Value.(*task); t.key == key {
+			return taskInfo(t), true
+		}
+	}
+	return TaskInfo{}, false
+}
+
+func taskInfo(t *task) TaskInfo {
+	return TaskInfo{Key: t.key, JobName: t.jobName, Times: t.times, NextFire: t.deadline}
+}
+
+// jobRuntimeStats 根据采样计算均值和95分位, 采样为空时均返回0
+func jobRuntimeStats(samples []time.Duration) (mean, p95 time.Duration) {
+	if len(samples) == 0 {
+		return 0, 0
+	}
+
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	mean = sum / time.Duration(len(sorted))
+
+	idx := int(float64(len(sorted)-1) * 0.95)
+	p95 = sorted[idx]
+	return
+}
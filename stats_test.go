@@ -0,0 +1,143 @@
+package timewheel
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTimeWheelListAndGet 验证List()/Get()在任务添加和移除后各阶段的一致性
+func TestTimeWheelListAndGet(t *testing.T) {
+	// interval使用整秒: getPositionAndCircle以tw.interval.Seconds()为除数计算圈数,
+	// 亚秒级interval会截断成0导致除零panic, 这是与List/Get无关的既有限制, 测试绕开它
+	tw := New(time.Second, 8)
+	tw.Start()
+	defer tw.Stop()
+
+	if err := tw.AddTask(time.Hour, 1, "list-key", nil, func(TaskData) {}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	// 给consumer goroutine一点时间把addTaskChannel里的任务处理进slots
+	time.Sleep(20 * time.Millisecond)
+
+	if info, ok := tw.Get("list-key"); !ok || info.Key != "list-key" {
+		t.Fatalf("Get(list-key) = %+v, %v, want a match", info, ok)
+	}
+	if _, ok := tw.Get("missing-key"); ok {
+		t.Fatal("Get(missing-key) ok = true, want false")
+	}
+
+	found := false
+	for _, info := range tw.List() {
+		if info.Key == "list-key" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("List() does not contain list-key")
+	}
+
+	if err := tw.RemoveTask("list-key"); err != nil {
+		t.Fatalf("RemoveTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := tw.Get("list-key"); ok {
+		t.Fatal("Get(list-key) ok = true after RemoveTask, want false")
+	}
+	for _, info := range tw.List() {
+		if info.Key == "list-key" {
+			t.Fatal("List() still contains list-key after RemoveTask")
+		}
+	}
+}
+
+// TestTimeWheelStatsPendingTasks 验证Stats().PendingTasks/SlotHistogram与实际挂载的
+// 任务数吻合
+func TestTimeWheelStatsPendingTasks(t *testing.T) {
+	tw := New(time.Hour, 4) // 用足够大的interval确保测试期间不会真的触发
+	tw.Start()
+	defer tw.Stop()
+
+	for i := 0; i < 3; i++ {
+		key := string(rune('a' + i))
+		if err := tw.AddTask(time.Hour, 1, key, nil, func(TaskData) {}); err != nil {
+			t.Fatalf("AddTask(%s): %v", key, err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	stats := tw.Stats()
+	if stats.PendingTasks != 3 {
+		t.Fatalf("PendingTasks = %d, want 3", stats.PendingTasks)
+	}
+	if stats.SlotNum != 4 {
+		t.Fatalf("SlotNum = %d, want 4", stats.SlotNum)
+	}
+
+	sum := 0
+	for _, n := range stats.SlotHistogram {
+		sum += n
+	}
+	if sum != 3 {
+		t.Fatalf("sum(SlotHistogram) = %d, want 3", sum)
+	}
+}
+
+// TestHierarchicalListAndGet 与TestTimeWheelListAndGet相同, 但针对HierarchicalTimeWheel
+func TestHierarchicalListAndGet(t *testing.T) {
+	htw := NewHierarchical(10*time.Millisecond, []int{10, 10}) // maxSpan=1s
+	htw.Start()
+	defer htw.Stop()
+
+	if err := htw.AddTask(500*time.Millisecond, 1, "list-key", nil, func(TaskData) {}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if info, ok := htw.Get("list-key"); !ok || info.Key != "list-key" {
+		t.Fatalf("Get(list-key) = %+v, %v, want a match", info, ok)
+	}
+	if _, ok := htw.Get("missing-key"); ok {
+		t.Fatal("Get(missing-key) ok = true, want false")
+	}
+
+	found := false
+	for _, info := range htw.List() {
+		if info.Key == "list-key" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("List() does not contain list-key")
+	}
+
+	if err := htw.RemoveTask("list-key"); err != nil {
+		t.Fatalf("RemoveTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := htw.Get("list-key"); ok {
+		t.Fatal("Get(list-key) ok = true after RemoveTask, want false")
+	}
+}
+
+// TestJobRuntimeStats 验证jobRuntimeStats对均值和95分位的计算, 以及空采样时的零值
+func TestJobRuntimeStats(t *testing.T) {
+	if mean, p95 := jobRuntimeStats(nil); mean != 0 || p95 != 0 {
+		t.Fatalf("jobRuntimeStats(nil) = %v, %v, want 0, 0", mean, p95)
+	}
+
+	samples := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		30 * time.Millisecond,
+		40 * time.Millisecond,
+	}
+	mean, p95 := jobRuntimeStats(samples)
+	if want := 25 * time.Millisecond; mean != want {
+		t.Fatalf("mean = %v, want %v", mean, want)
+	}
+	if want := 30 * time.Millisecond; p95 != want { // idx = int((4-1)*0.95) = 2 -> sorted[2]
+		t.Fatalf("p95 = %v, want %v", p95, want)
+	}
+}
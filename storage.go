@@ -0,0 +1,169 @@
+package timewheel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// StoredTask 任务的可持久化表示。使用绝对截止时间而不是剩余延迟, 并以注册过的job名
+// 而不是函数值来关联回调, 这样才能在进程重启后正确复原
+type StoredTask struct {
+	Key      interface{}
+	JobName  string
+	Data     TaskData
+	Deadline time.Time
+	Interval time.Duration
+	Times    int
+}
+
+// Storage 任务持久化存储后端, 用于进程重启后恢复尚未触发的任务
+type Storage interface {
+	Save(task StoredTask) error
+	Delete(key interface{}) error
+	LoadDue(before time.Time) ([]StoredTask, error)
+	LoadAll() ([]StoredTask, error)
+}
+
+// WithStorage 为时间轮配置持久化存储后端。配置后, Start()会先调用LoadAll恢复所有任务,
+// 之后通过AddNamedTask添加的任务在每次add/update/remove时都会同步镜像到存储;
+// AddTask添加的匿名任务不会被保存
+func WithStorage(s Storage) Option {
+	return func(o *wheelOptions) {
+		o.storage = s
+	}
+}
+
+var (
+	jobRegistryMu sync.RWMutex
+	jobRegistry   = make(map[string]Job)
+)
+
+// RegisterJob 以name注册一个回调函数。持久化任务只保存JobName, 重启后通过该注册表
+// 找回对应的Job, 因此使用Storage的任务必须通过已注册的名字添加
+func RegisterJob(name string, fn Job) {
+	jobRegistryMu.Lock()
+	defer jobRegistryMu.Unlock()
+	jobRegistry[name] = fn
+}
+
+func init() {
+	// 预注册AddTask/AddNamedTask中常见的key类型, 使EncodeKey/DecodeKey对它们开箱即用;
+	// 其他自定义类型和TaskData中出现的自定义类型一样, 需要调用方自行RegisterGobType
+	gob.Register("")
+	gob.Register(0)
+	gob.Register(int64(0))
+	gob.Register(float64(0))
+}
+
+// EncodeKey 将任务key编码为字节, 供Storage的具体实现落盘时保留key原本的动态类型,
+// 而不是像fmt.Sprint那样把所有key都压成字符串。key的类型需要能被gob编码, 内置的
+// string/int/int64/float64已预先注册, 其余自定义类型需要调用方先RegisterGobType
+func EncodeKey(key interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&key); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DecodeKey 解码EncodeKey编码的字节, 还原出原始key及其动态类型
+func DecodeKey(b []byte) (interface{}, error) {
+	var key interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func lookupJob(name string) (Job, bool) {
+	jobRegistryMu.RLock()
+	defer jobRegistryMu.RUnlock()
+	fn, ok := jobRegistry[name]
+	return fn, ok
+}
+
+// TaskDataCodec 负责TaskData的序列化和反序列化, 供Storage的具体实现落盘/写入不同后端使用
+type TaskDataCodec interface {
+	Encode(TaskData) ([]byte, error)
+	Decode([]byte) (TaskData, error)
+}
+
+// DefaultCodec 默认使用JSON编解码TaskData
+var DefaultCodec TaskDataCodec = jsonCodec{}
+
+// GobCodec 基于encoding/gob的TaskDataCodec实现, TaskData中出现的自定义类型
+// 需要提前通过RegisterGobType注册
+var GobCodec TaskDataCodec = gobCodec{}
+
+// RegisterGobType 注册使用GobCodec编解码TaskData时可能出现的自定义类型
+func RegisterGobType(v interface{}) {
+	gob.Register(v)
+}
+
+// jsonEntry 是jsonCodec落盘时TaskData一个键值对的载体。Key用EncodeKey/DecodeKey
+// (而不是fmt.Sprint)编解码, 这样恢复出来的key能保留原本的动态类型(如int), 不会被
+// 统一压成string导致job里按原类型查找data[k]静默miss
+type jsonEntry struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// jsonCodec 将TaskData编码为JSON数组, value本身仍按json.Marshal/Unmarshal的通用规则
+// 往返(例如int会变回float64), 调用方需要以JSON能表达的方式使用TaskData里的值
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(d TaskData) ([]byte, error) {
+	entries := make([]jsonEntry, 0, len(d))
+	for k, v := range d {
+		kb, err := EncodeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, jsonEntry{Key: base64.StdEncoding.EncodeToString(kb), Value: v})
+	}
+	return json.Marshal(entries)
+}
+
+func (jsonCodec) Decode(b []byte) (TaskData, error) {
+	var entries []jsonEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+
+	d := make(TaskData, len(entries))
+	for _, e := range entries {
+		kb, err := base64.StdEncoding.DecodeString(e.Key)
+		if err != nil {
+			return nil, err
+		}
+		key, err := DecodeKey(kb)
+		if err != nil {
+			return nil, err
+		}
+		d[key] = e.Value
+	}
+	return d, nil
+}
+
+// gobCodec 直接编解码map[interface{}]interface{}, 值里的自定义类型需要先RegisterGobType
+type gobCodec struct{}
+
+func (gobCodec) Encode(d TaskData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(map[interface{}]interface{}(d)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(b []byte) (TaskData, error) {
+	var m map[interface{}]interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&m); err != nil {
+		return nil, err
+	}
+	return TaskData(m), nil
+}
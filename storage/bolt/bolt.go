@@ -0,0 +1,206 @@
+// Package boltstore 提供基于bbolt的文件型timewheel.Storage实现,
+// 适合单机、单进程场景下的延迟任务持久化
+package boltstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/yuud/timewheel"
+	bolt "go.etcd.io/bbolt"
+)
+
+var tasksBucket = []byte("timewheel_tasks")
+
+// Store 基于bbolt的timewheel.Storage实现
+type Store struct {
+	db    *bolt.DB
+	codec timewheel.TaskDataCodec
+}
+
+// Open 打开(或创建)path处的bbolt文件作为存储后端
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tasksBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db, codec: timewheel.DefaultCodec}, nil
+}
+
+// Close 关闭底层bbolt文件
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// record 是StoredTask落盘时的载体
+type record struct {
+	JobName  string
+	Data     []byte
+	Deadline time.Time
+	Interval time.Duration
+	Times    int
+}
+
+func (s *Store) Save(task timewheel.StoredTask) error {
+	data, err := s.codec.Encode(task.Data)
+	if err != nil {
+		return err
+	}
+	key, err := timewheel.EncodeKey(task.Key)
+	if err != nil {
+		return err
+	}
+
+	rec := record{JobName: task.JobName, Data: data, Deadline: task.Deadline, Interval: task.Interval, Times: task.Times}
+	value, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).Put(key, value)
+	})
+}
+
+func (s *Store) Delete(key interface{}) error {
+	keyBytes, err := timewheel.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(tasksBucket).Delete(keyBytes)
+	})
+}
+
+func (s *Store) LoadDue(before time.Time) ([]timewheel.StoredTask, error) {
+	return s.load(func(rec record) bool {
+		return rec.Deadline.Before(before)
+	})
+}
+
+func (s *Store) LoadAll() ([]timewheel.StoredTask, error) {
+	return s.load(func(record) bool { return true })
+}
+
+func (s *Store) load(match func(record) bool) ([]timewheel.StoredTask, error) {
+	var out []timewheel.StoredTask
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tasksBucket)
+		return b.ForEach(func(k, v []byte) error {
+			rec, err := decodeRecord(v)
+			if err != nil {
+				return err
+			}
+			if !match(rec) {
+				return nil
+			}
+
+			data, err := s.codec.Decode(rec.Data)
+			if err != nil {
+				return err
+			}
+			origKey, err := timewheel.DecodeKey(k)
+			if err != nil {
+				return err
+			}
+
+			out = append(out, timewheel.StoredTask{
+				Key:      origKey,
+				JobName:  rec.JobName,
+				Data:     data,
+				Deadline: rec.Deadline,
+				Interval: rec.Interval,
+				Times:    rec.Times,
+			})
+			return nil
+		})
+	})
+
+	return out, err
+}
+
+// encodeRecord/decodeRecord 使用简单的二进制格式(长度前缀的字节块+定长整数), 避免额外依赖
+func encodeRecord(rec record) ([]byte, error) {
+	var buf bytes.Buffer
+
+	deadline, err := rec.Deadline.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	writeChunk(&buf, []byte(rec.JobName))
+	writeChunk(&buf, rec.Data)
+	writeChunk(&buf, deadline)
+	_ = binary.Write(&buf, binary.BigEndian, int64(rec.Interval))
+	_ = binary.Write(&buf, binary.BigEndian, int64(rec.Times))
+
+	return buf.Bytes(), nil
+}
+
+func decodeRecord(b []byte) (record, error) {
+	buf := bytes.NewReader(b)
+
+	jobName, err := readChunk(buf)
+	if err != nil {
+		return record{}, err
+	}
+	data, err := readChunk(buf)
+	if err != nil {
+		return record{}, err
+	}
+	deadlineBytes, err := readChunk(buf)
+	if err != nil {
+		return record{}, err
+	}
+
+	var deadline time.Time
+	if err := deadline.UnmarshalBinary(deadlineBytes); err != nil {
+		return record{}, err
+	}
+
+	var interval, times int64
+	if err := binary.Read(buf, binary.BigEndian, &interval); err != nil {
+		return record{}, err
+	}
+	if err := binary.Read(buf, binary.BigEndian, &times); err != nil {
+		return record{}, err
+	}
+
+	return record{
+		JobName:  string(jobName),
+		Data:     data,
+		Deadline: deadline,
+		Interval: time.Duration(interval),
+		Times:    int(times),
+	}, nil
+}
+
+func writeChunk(buf *bytes.Buffer, chunk []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(chunk)))
+	buf.Write(chunk)
+}
+
+func readChunk(r *bytes.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, n)
+	if _, err := io.ReadFull(r, chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
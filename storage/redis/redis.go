@@ -0,0 +1,167 @@
+// Package redisstore 提供基于Redis的timewheel.Storage实现。
+// 任务内容存放在一个hash里, 到期时间额外维护在一个sorted set(score为Unix纳秒)里,
+// 以便LoadDue能够用ZRANGEBYSCORE高效地按到期时间范围查询
+package redisstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/yuud/timewheel"
+)
+
+// Store 基于Redis的timewheel.Storage实现
+type Store struct {
+	client    *redis.Client
+	keyPrefix string
+	codec     timewheel.TaskDataCodec
+}
+
+// New 基于已有的redis.Client创建Store, keyPrefix用于隔离同一个Redis实例上的多个时间轮
+func New(client *redis.Client, keyPrefix string) *Store {
+	return &Store{client: client, keyPrefix: keyPrefix, codec: timewheel.DefaultCodec}
+}
+
+func (s *Store) hashKey() string {
+	return s.keyPrefix + ":tasks"
+}
+
+func (s *Store) zsetKey() string {
+	return s.keyPrefix + ":deadlines"
+}
+
+// record 是StoredTask在Redis中的存储形式。Key保存EncodeKey编码后的原始key,
+// 使重启恢复时能找回key本来的动态类型, 而不是统一还原成hash field那样的string
+type record struct {
+	Key      []byte        `json:"key"`
+	JobName  string        `json:"job_name"`
+	Data     string        `json:"data"`
+	Deadline time.Time     `json:"deadline"`
+	Interval time.Duration `json:"interval"`
+	Times    int           `json:"times"`
+}
+
+func (s *Store) Save(task timewheel.StoredTask) error {
+	ctx := context.Background()
+
+	data, err := s.codec.Encode(task.Data)
+	if err != nil {
+		return err
+	}
+	encodedKey, err := timewheel.EncodeKey(task.Key)
+	if err != nil {
+		return err
+	}
+	key := fieldKey(encodedKey)
+
+	rec := record{Key: encodedKey, JobName: task.JobName, Data: string(data), Deadline: task.Deadline, Interval: task.Interval, Times: task.Times}
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, s.hashKey(), key, value)
+	pipe.ZAdd(ctx, s.zsetKey(), redis.Z{Score: float64(task.Deadline.UnixNano()), Member: key})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (s *Store) Delete(key interface{}) error {
+	ctx := context.Background()
+
+	encodedKey, err := timewheel.EncodeKey(key)
+	if err != nil {
+		return err
+	}
+	field := fieldKey(encodedKey)
+
+	pipe := s.client.TxPipeline()
+	pipe.HDel(ctx, s.hashKey(), field)
+	pipe.ZRem(ctx, s.zsetKey(), field)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// LoadDue 返回 before 之前到期的所有任务, 借助sorted set按score范围查询
+func (s *Store) LoadDue(before time.Time) ([]timewheel.StoredTask, error) {
+	ctx := context.Background()
+
+	fields, err := s.client.ZRangeByScore(ctx, s.zsetKey(), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", before.UnixNano()),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadFields(ctx, fields)
+}
+
+// LoadAll 返回存储中的全部任务
+func (s *Store) LoadAll() ([]timewheel.StoredTask, error) {
+	ctx := context.Background()
+
+	fields, err := s.client.ZRange(ctx, s.zsetKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadFields(ctx, fields)
+}
+
+func (s *Store) loadFields(ctx context.Context, fields []string) ([]timewheel.StoredTask, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	values, err := s.client.HMGet(ctx, s.hashKey(), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]timewheel.StoredTask, 0, len(values))
+	for _, v := range values {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal([]byte(str), &rec); err != nil {
+			return nil, err
+		}
+
+		data, err := s.codec.Decode([]byte(rec.Data))
+		if err != nil {
+			return nil, err
+		}
+		origKey, err := timewheel.DecodeKey(rec.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, timewheel.StoredTask{
+			Key:      origKey,
+			JobName:  rec.JobName,
+			Data:     data,
+			Deadline: rec.Deadline,
+			Interval: rec.Interval,
+			Times:    rec.Times,
+		})
+	}
+
+	return out, nil
+}
+
+// fieldKey 将EncodeKey编码后的字节转成hash field/zset member可用的字符串。
+// 不能像之前那样用fmt.Sprint(key)对原始key取字符串表示: 不同动态类型但字符串形式相同的
+// key(例如int类型的1和string类型的"1")会被压成同一个field, 导致Save/Delete互相覆盖或
+// 误删, 尽管record.Key本身早已用EncodeKey/DecodeKey正确保留了原始类型
+func fieldKey(encodedKey []byte) string {
+	return base64.StdEncoding.EncodeToString(encodedKey)
+}
@@ -0,0 +1,26 @@
+package redisstore
+
+import (
+	"testing"
+
+	"github.com/yuud/timewheel"
+)
+
+// TestFieldKeyDistinguishesKeyTypes 回归测试: fieldKey此前直接fmt.Sprint(key),
+// 类型不同但字符串表示相同的key(例如int类型的1和string类型的"1")会被压成同一个
+// hash field/zset member, 导致Save/Delete互相覆盖或误删。现在改为对EncodeKey的结果
+// base64编码, 不同类型的key即便Sprint结果相同也不会再碰撞
+func TestFieldKeyDistinguishesKeyTypes(t *testing.T) {
+	intKey, err := timewheel.EncodeKey(1)
+	if err != nil {
+		t.Fatalf("EncodeKey(1): %v", err)
+	}
+	strKey, err := timewheel.EncodeKey("1")
+	if err != nil {
+		t.Fatalf("EncodeKey(\"1\"): %v", err)
+	}
+
+	if fieldKey(intKey) == fieldKey(strKey) {
+		t.Fatalf("fieldKey collided for int(1) and string(%q): %q", "1", fieldKey(intKey))
+	}
+}
@@ -0,0 +1,246 @@
+package timewheel
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memStorage 是一个最简单的内存版Storage实现, 用map模拟"重启后仍在"的持久化数据,
+// 供测试在不依赖真实bolt/redis的情况下验证Save/Delete/LoadAll的往返和重启恢复行为
+type memStorage struct {
+	mu    sync.Mutex
+	tasks map[string]StoredTask
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{tasks: make(map[string]StoredTask)}
+}
+
+func (s *memStorage) fieldKey(key interface{}) string {
+	b, err := EncodeKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func (s *memStorage) Save(task StoredTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[s.fieldKey(task.Key)] = task
+	return nil
+}
+
+func (s *memStorage) Delete(key interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tasks, s.fieldKey(key))
+	return nil
+}
+
+func (s *memStorage) LoadDue(before time.Time) ([]StoredTask, error) {
+	var out []StoredTask
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.Deadline.Before(before) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func (s *memStorage) LoadAll() ([]StoredTask, error) {
+	var out []StoredTask
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (s *memStorage) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.tasks)
+}
+
+// TestAddNamedTaskPersistsAndRemoveTaskUnpersists 验证只有AddNamedTask添加的任务会被
+// 镜像到storage, 且RemoveTask会把它从storage里一并删掉
+func TestAddNamedTaskPersistsAndRemoveTaskUnpersists(t *testing.T) {
+	RegisterJob("storage-test-job", func(TaskData) {})
+
+	store := newMemStorage()
+	tw := New(time.Second, 8, WithStorage(store))
+	tw.Start()
+	defer tw.Stop()
+
+	if err := tw.AddTask(time.Hour, 1, "anon-key", nil, func(TaskData) {}); err != nil {
+		t.Fatalf("AddTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := store.count(); got != 0 {
+		t.Fatalf("storage count after anonymous AddTask = %d, want 0 (anonymous jobs aren't persisted)", got)
+	}
+
+	if err := tw.AddNamedTask(time.Hour, 1, "named-key", nil, "storage-test-job"); err != nil {
+		t.Fatalf("AddNamedTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := store.count(); got != 1 {
+		t.Fatalf("storage count after AddNamedTask = %d, want 1", got)
+	}
+
+	if err := tw.RemoveTask("named-key"); err != nil {
+		t.Fatalf("RemoveTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := store.count(); got != 0 {
+		t.Fatalf("storage count after RemoveTask = %d, want 0", got)
+	}
+}
+
+// TestTimeWheelRestoresNamedTasksOnStart 模拟进程重启: 一个TimeWheel把命名任务存进
+// store后, 另一个全新的TimeWheel(代表重启后的进程)用同一个store调用Start(),
+// 应当恢复该任务并在原定时刻正常触发
+func TestTimeWheelRestoresNamedTasksOnStart(t *testing.T) {
+	fired := make(chan TaskData, 1)
+	RegisterJob("restore-test-job", func(d TaskData) {
+		fired <- d
+	})
+
+	store := newMemStorage()
+
+	old := New(time.Second, 8, WithStorage(store))
+	old.Start()
+	if err := old.AddNamedTask(50*time.Millisecond, 1, "restore-key", TaskData{"n": 1}, "restore-test-job"); err != nil {
+		t.Fatalf("AddNamedTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	old.Stop()
+
+	if got := store.count(); got != 1 {
+		t.Fatalf("storage count before restart = %d, want 1", got)
+	}
+
+	restarted := New(time.Second, 8, WithStorage(store))
+	restarted.Start()
+	defer restarted.Stop()
+
+	select {
+	case d := <-fired:
+		if n, _ := d["n"].(int); n != 1 {
+			t.Fatalf("restored task data = %+v, want n=1", d)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("restored task never fired after restart")
+	}
+}
+
+// TestHierarchicalRestoresNamedTasksOnStart 与TestTimeWheelRestoresNamedTasksOnStart相同,
+// 但针对HierarchicalTimeWheel
+func TestHierarchicalRestoresNamedTasksOnStart(t *testing.T) {
+	var fires int32
+	RegisterJob("hierarchical-restore-test-job", func(TaskData) {
+		atomic.AddInt32(&fires, 1)
+	})
+
+	store := newMemStorage()
+
+	old := NewHierarchical(10*time.Millisecond, []int{10, 10}, WithStorage(store))
+	old.Start()
+	if err := old.AddNamedTask(200*time.Millisecond, 1, "h-restore-key", nil, "hierarchical-restore-test-job"); err != nil {
+		t.Fatalf("AddNamedTask: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	old.Stop()
+
+	if got := store.count(); got != 1 {
+		t.Fatalf("storage count before restart = %d, want 1", got)
+	}
+
+	restarted := NewHierarchical(10*time.Millisecond, []int{10, 10}, WithStorage(store))
+	restarted.Start()
+	defer restarted.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt32(&fires) > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("restored task never fired after restart")
+}
+
+// TestEncodeDecodeKeyRoundTrip 验证EncodeKey/DecodeKey对内置key类型的往返保真,
+// 包括值相同但类型不同(int(1)与"1")的key不会被互相混淆
+func TestEncodeDecodeKeyRoundTrip(t *testing.T) {
+	cases := []interface{}{"1", 1, int64(1), 3.14}
+
+	for _, key := range cases {
+		b, err := EncodeKey(key)
+		if err != nil {
+			t.Fatalf("EncodeKey(%#v): %v", key, err)
+		}
+		got, err := DecodeKey(b)
+		if err != nil {
+			t.Fatalf("DecodeKey(EncodeKey(%#v)): %v", key, err)
+		}
+		if got != key {
+			t.Fatalf("DecodeKey(EncodeKey(%#v)) = %#v, want %#v", key, got, key)
+		}
+	}
+
+	intKey, _ := EncodeKey(1)
+	strKey, _ := EncodeKey("1")
+	if string(intKey) == string(strKey) {
+		t.Fatal("EncodeKey(1) and EncodeKey(\"1\") collided")
+	}
+}
+
+// TestJSONCodecRoundTrip 验证jsonCodec能还原TaskData里键的原始动态类型(不会被
+// fmt.Sprint式地压成字符串), 即便不同类型的key字符串表示相同
+func TestJSONCodecRoundTrip(t *testing.T) {
+	data := TaskData{1: "int-key", "1": "string-key"}
+
+	b, err := DefaultCodec.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err := DefaultCodec.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got[1] != "int-key" {
+		t.Fatalf("got[1] = %v, want %q", got[1], "int-key")
+	}
+	if got["1"] != "string-key" {
+		t.Fatalf(`got["1"] = %v, want %q`, got["1"], "string-key")
+	}
+}
+
+// TestGobCodecRoundTrip 验证gobCodec对TaskData的往返
+func TestGobCodecRoundTrip(t *testing.T) {
+	data := TaskData{1: "int-key", "1": "string-key"}
+
+	b, err := GobCodec.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err := GobCodec.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got[1] != "int-key" {
+		t.Fatalf("got[1] = %v, want %q", got[1], "int-key")
+	}
+	if got["1"] != "string-key" {
+		t.Fatalf(`got["1"] = %v, want %q`, got["1"], "string-key")
+	}
+}
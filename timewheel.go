@@ -3,6 +3,8 @@ package timewheel
 import (
 	"container/list"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,9 +19,29 @@ type TimeWheel struct {
 	addTaskChannel    chan task           //新增任务channel
 	updateTaskChannel chan uptask         //更新任务channel
 	removeTaskChannel chan interface{}    //删除任务channel
-	stopChannel       chan bool           //停止定时器channel
+	stopChannel       chan struct{}       //停止定时器channel, 只会被close一次
+	stopOnce          sync.Once           //保证stopChannel只被close一次
+	stopped           bool                //由mu保护, Stop()置true后新的发送方法调用一律返回ErrStopped
+	sendWG            sync.WaitGroup      //Stop()据此等待所有已经通过stopped判断、正在发送的调用完成, 才去关闭stopChannel
+	mu                sync.RWMutex        //保护timer和slots, 使List/Stats等巡检接口可以安全并发读取
+	storage           Storage             //可选的持久化存储后端
+	pool              *jobPool            //执行到期任务回调的worker池
+
+	tasksFired   uint64          //累计已触发的任务数, 用于Stats()
+	tasksDropped uint64          //累计因过载等原因被丢弃的任务数, 用于Stats()
+	statsMu      sync.Mutex      //保护lastTick/tickLag/jobDurations
+	lastTick     time.Time       //上一次tick发生的时间, 用于计算tickLag
+	tickLag      time.Duration   //最近一次tick相对期望触发时间的滞后
+	jobDurations []time.Duration //最近若干次job执行耗时的采样, 用于估算mean/p95
 }
 
+// maxJobDurationSamples 限制jobDurations采样个数, 避免长期运行后无限增长
+const maxJobDurationSamples = 256
+
+// pendingPos 是tw.timer中key的占位位置, 表示该key已被reserveAndSend预占但实际任务尚未插入任何槽位。
+// 真正的槽位下标恒为[0, slotNum), 因此-1可以安全地用作哨兵值
+const pendingPos = -1
+
 // Job 任务回调函数
 type Job func(TaskData)
 
@@ -34,6 +56,8 @@ type task struct {
 	key      interface{}   //定时器唯一标识
 	job      Job           //回调函数
 	taskData TaskData      //回调函数参数
+	deadline time.Time     //下一次触发的绝对时间, 用于持久化(LoadDue/LoadAll)和List/Get返回的NextFire
+	jobName  string        //通过RegisterJob注册的job名, 非空时该任务会被镜像到storage
 }
 
 // uptask 结构体
@@ -44,36 +68,87 @@ type uptask struct {
 }
 
 // New 创建时间轮
-func New(interval time.Duration, slotNum int) *TimeWheel {
+func New(interval time.Duration, slotNum int, opts ...Option) *TimeWheel {
 	if interval <= 0 || slotNum <= 0 {
 		return nil
 	}
+
+	o := defaultWheelOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	tw := &TimeWheel{
 		interval:          interval,
 		slots:             make([]*list.List, slotNum),
 		timer:             make(map[interface{}]int),
 		currentPos:        0,
 		slotNum:           slotNum,
-		addTaskChannel:    make(chan task),
-		updateTaskChannel: make(chan uptask),
-		removeTaskChannel: make(chan interface{}),
-		stopChannel:       make(chan bool),
+		addTaskChannel:    make(chan task, o.chanSize),
+		updateTaskChannel: make(chan uptask, o.chanSize),
+		removeTaskChannel: make(chan interface{}, o.chanSize),
+		stopChannel:       make(chan struct{}),
+		storage:           o.storage,
 	}
 
 	tw.init()
+	tw.pool = newJobPool(o.workers, o.workers*defaultQueueMultiplier, o.jobTimeout, o.onPanic, func(key interface{}) {
+		atomic.AddUint64(&tw.tasksDropped, 1)
+		if o.onOverflow != nil {
+			o.onOverflow(key)
+		}
+	})
 
 	return tw
 }
 
-// Start 启动时间轮
+// Start 启动时间轮。若配置了Storage, 会先加载其中保存的全部任务并重新调度
 func (tw *TimeWheel) Start() {
+	tw.restoreFromStorage()
 	tw.ticker = time.NewTicker(tw.interval)
 	go tw.start()
 }
 
-// Stop 停止时间轮
+// restoreFromStorage 从storage加载所有任务并重新插入轮盘, 找不到对应注册job的任务会被跳过
+func (tw *TimeWheel) restoreFromStorage() {
+	if tw.storage == nil {
+		return
+	}
+
+	stored, err := tw.storage.LoadAll()
+	if err != nil {
+		return
+	}
+
+	for _, st := range stored {
+		job, ok := lookupJob(st.JobName)
+		if !ok {
+			continue
+		}
+
+		delay := time.Until(st.Deadline)
+		if delay <= 0 {
+			delay = time.Nanosecond
+		}
+
+		// 用delay(距离下次触发的剩余时间)定位槽位, 但保留st.Interval作为任务真实的周期间隔;
+		// 若这里误把delay当成task.interval, 周期任务重启后就会按"重启时刻剩余的时间"永久循环,
+		// 而不是原本配置的Interval
+		t := &task{interval: st.Interval, times: st.Times, key: st.Key, job: job, taskData: st.Data, deadline: st.Deadline, jobName: st.JobName}
+		tw.addRestoredTask(t, delay)
+	}
+}
+
+// Stop 停止时间轮, 可安全地并发/重复调用
 func (tw *TimeWheel) Stop() {
-	tw.stopChannel <- true
+	tw.stopOnce.Do(func() {
+		tw.mu.Lock()
+		tw.stopped = true
+		tw.mu.Unlock()
+		tw.sendWG.Wait()
+		close(tw.stopChannel)
+		tw.pool.stop()
+	})
 }
 
 func (tw *TimeWheel) start() {
@@ -94,25 +169,99 @@ func (tw *TimeWheel) start() {
 	}
 }
 
-func (tw *TimeWheel) AddTask(interval time.Duration, times int, key interface{}, data TaskData, job Job) {
+// reserveAndSend 为key预占pendingPos占位, 登记一次在途发送(sendWG), 再把任务发送到
+// addTaskChannel。stopped判断和sendWG.Add必须在同一个mu临界区内完成, 这样Stop()的
+// "置stopped=true(持有mu) -> 释放mu -> sendWG.Wait()"序列就能保证: 每一次成功的Add都
+// 发生在stopped被置true之前, 因此必然先于之后的Wait调用完成, Wait絶不会在还有in-flight
+// 发送时提前返回, close(stopChannel)也就不会早于所有已放行的发送完成。不这样做的话,
+// close(stopChannel)可能恰好发生在判断之后、发送之前, select会在"channel有空位"和
+// "stopChannel已关闭"两个就绪分支间随机选择, 导致任务悄悄进入一个consumer已经退出、
+// 永远不会再被消费的channel, 同时其pendingPos占位永久卡住该key。
+// 发送本身必须在mu之外进行: addTaskChannel的consumer处理完一个任务同样要拿mu, 若这里
+// 发送时还攥着mu, buffer打满时consumer会因拿不到mu而无法腾出新的空位, 造成死锁
+func (tw *TimeWheel) reserveAndSend(key interface{}, t task) error {
+	tw.mu.Lock()
+	if tw.stopped {
+		tw.mu.Unlock()
+		return ErrStopped
+	}
+	if _, exists := tw.timer[key]; exists {
+		tw.mu.Unlock()
+		return ErrDuplicateKey
+	}
+	tw.timer[key] = pendingPos
+	tw.sendWG.Add(1)
+	tw.mu.Unlock()
+
+	defer tw.sendWG.Done()
+	tw.addTaskChannel <- t
+	return nil
+}
+
+// AddTask 添加任务。时间轮已停止时返回 ErrStopped, key已存在时返回 ErrDuplicateKey
+func (tw *TimeWheel) AddTask(interval time.Duration, times int, key interface{}, data TaskData, job Job) error {
 	if interval <= 0 || key == nil || job == nil {
-		return
+		return ErrInvalidTask
+	}
+
+	return tw.reserveAndSend(key, task{interval: interval, times: times, key: key, taskData: data, job: job})
+}
+
+// AddNamedTask 与AddTask相同, 但job必须已通过RegisterJob以jobName注册。
+// 当时间轮配置了Storage时, 只有通过AddNamedTask添加的任务才会被持久化,
+// 因为重启后只能凭jobName从注册表中找回回调函数
+func (tw *TimeWheel) AddNamedTask(interval time.Duration, times int, key interface{}, data TaskData, jobName string) error {
+	job, ok := lookupJob(jobName)
+	if !ok {
+		return fmt.Errorf("timewheel: job %q is not registered", jobName)
 	}
-	tw.addTaskChannel <- task{interval: interval, times: times, key: key, taskData: data, job: job}
+	if interval <= 0 || key == nil {
+		return ErrInvalidTask
+	}
+
+	return tw.reserveAndSend(key, task{interval: interval, times: times, key: key, taskData: data, job: job, jobName: jobName})
 }
 
-func (tw *TimeWheel) UpdateTask(key interface{}, interval time.Duration, data TaskData) {
+// UpdateTask 更新任务的执行间隔和参数。时间轮已停止时返回 ErrStopped,
+// interval非法(<=0)时返回ErrInvalidTask, 防止负的interval传入getPositionAndCircle算出负的槽位下标
+func (tw *TimeWheel) UpdateTask(key interface{}, interval time.Duration, data TaskData) error {
 	if key == nil {
-		return
+		return ErrInvalidKey
+	}
+	if interval <= 0 {
+		return ErrInvalidTask
+	}
+
+	tw.mu.Lock()
+	if tw.stopped {
+		tw.mu.Unlock()
+		return ErrStopped
 	}
+	tw.sendWG.Add(1)
+	tw.mu.Unlock()
+
+	defer tw.sendWG.Done()
 	tw.updateTaskChannel <- uptask{key: key, interval: interval, taskData: data}
+	return nil
 }
 
-func (tw *TimeWheel) RemoveTask(key interface{}) {
+// RemoveTask 移除任务。时间轮已停止时返回 ErrStopped
+func (tw *TimeWheel) RemoveTask(key interface{}) error {
 	if key == nil {
-		return
+		return ErrInvalidKey
+	}
+
+	tw.mu.Lock()
+	if tw.stopped {
+		tw.mu.Unlock()
+		return ErrStopped
 	}
+	tw.sendWG.Add(1)
+	tw.mu.Unlock()
+
+	defer tw.sendWG.Done()
 	tw.removeTaskChannel <- key
+	return nil
 }
 
 // 时间轮初始化
@@ -124,6 +273,8 @@ func (tw *TimeWheel) init() {
 
 //
 func (tw *TimeWheel) tickHandler() {
+	tw.recordTick()
+
 	l := tw.slots[tw.currentPos]
 	tw.scanAddRunTask(l)
 	if tw.currentPos == tw.slotNum-1 {
@@ -133,65 +284,156 @@ func (tw *TimeWheel) tickHandler() {
 	}
 }
 
+// recordTick 记录本次tick相对上一次tick+interval的滞后时间, 供Stats()上报tick-lag
+func (tw *TimeWheel) recordTick() {
+	now := time.Now()
+
+	tw.statsMu.Lock()
+	defer tw.statsMu.Unlock()
+
+	if !tw.lastTick.IsZero() {
+		tw.tickLag = now.Sub(tw.lastTick.Add(tw.interval))
+	}
+	tw.lastTick = now
+}
+
 // 添加任务
 func (tw *TimeWheel) addTask(task *task) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.addTaskLocked(task)
+}
+
+// addTaskLocked 添加任务, 调用方需已持有tw.mu
+func (tw *TimeWheel) addTaskLocked(task *task) {
 	pos, circle := tw.getPositionAndCircle(task.interval)
 	task.circle = circle
+	task.deadline = time.Now().Add(task.interval)
 
 	tw.slots[pos].PushBack(task)
 
 	tw.timer[task.key] = pos
+
+	tw.persist(task)
+}
+
+// addRestoredTask 将从storage恢复的任务按剩余延迟delay定位槽位和圈数, 但不改写task.interval和
+// task.deadline(均保持来自StoredTask的原值), 避免"重启时刻距离下次触发还有多久"覆盖任务真实的周期间隔
+func (tw *TimeWheel) addRestoredTask(t *task, delay time.Duration) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	pos, circle := tw.getPositionAndCircle(delay)
+	t.circle = circle
+
+	tw.slots[pos].PushBack(t)
+	tw.timer[t.key] = pos
+
+	tw.persist(t)
+}
+
+// persist 将任务镜像到storage。只有通过AddNamedTask添加、带有jobName的任务才会被持久化,
+// 因为匿名job函数值在进程重启后无法找回
+func (tw *TimeWheel) persist(t *task) {
+	if tw.storage == nil || t.jobName == "" {
+		return
+	}
+	_ = tw.storage.Save(StoredTask{
+		Key:      t.key,
+		JobName:  t.jobName,
+		Data:     t.taskData,
+		Deadline: t.deadline,
+		Interval: t.interval,
+		Times:    t.times,
+	})
+}
+
+// unpersist 从storage中移除任务
+func (tw *TimeWheel) unpersist(key interface{}) {
+	if tw.storage == nil {
+		return
+	}
+	_ = tw.storage.Delete(key)
+}
+
+// recordJobDuration 记录一次job执行耗时, 超出maxJobDurationSamples时丢弃最旧的采样
+func (tw *TimeWheel) recordJobDuration(d time.Duration) {
+	tw.statsMu.Lock()
+	defer tw.statsMu.Unlock()
+
+	tw.jobDurations = append(tw.jobDurations, d)
+	if len(tw.jobDurations) > maxJobDurationSamples {
+		tw.jobDurations = tw.jobDurations[len(tw.jobDurations)-maxJobDurationSamples:]
+	}
 }
 
 // 更新任务参数
 func (tw *TimeWheel) updateTask(update uptask) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
 	// 获取定时器所在的槽
 	key := update.key
 	position, ok := tw.timer[key]
-	if !ok {
+	if !ok || position == pendingPos {
 		return
 	}
-	fmt.Println(update)
+
 	// 获取槽指向的链表
-	l := *tw.slots[position]
-	for e := l.Front(); e != nil; {
+	l := tw.slots[position]
+	for e := l.Front(); e != nil; e = e.Next() {
 		task := e.Value.(*task)
-		if task.key == key { //修改
-			task.interval = update.interval
-			task.taskData = update.taskData
+		if task.key != key {
+			continue
 		}
 
-		e = e.Next()
+		task.interval = update.interval
+		task.taskData = update.taskData
+		task.deadline = time.Now().Add(update.interval)
+
+		// interval变化后所在槽位和圈数也要重新计算, 否则任务仍会在旧槽位触发
+		l.Remove(e)
+		pos, circle := tw.getPositionAndCircle(update.interval)
+		task.circle = circle
+		tw.slots[pos].PushBack(task)
+		tw.timer[key] = pos
+		tw.persist(task)
+		return
 	}
 }
 
 // 移除任务
 func (tw *TimeWheel) removeTask(key interface{}) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
 	// 获取定时器所在的槽
 	position, ok := tw.timer[key]
-	if !ok {
+	if !ok || position == pendingPos {
 		return
 	}
 	// 获取槽指向的链表
 	l := tw.slots[position]
-	for e := l.Front(); e != nil; {
+	for e := l.Front(); e != nil; e = e.Next() {
 		task := e.Value.(*task)
 		if task.key == key {
 			delete(tw.timer, task.key)
 			l.Remove(e)
+			tw.unpersist(key)
+			return
 		}
-
-		e = e.Next()
 	}
 }
 
 // 扫描链表中任务并执行回调函数
 func (tw *TimeWheel) scanAddRunTask(l *list.List) {
-
 	if l == nil {
 		return
 	}
 
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
 	for item := l.Front(); item != nil; {
 		task := item.Value.(*task)
 
@@ -199,6 +441,7 @@ func (tw *TimeWheel) scanAddRunTask(l *list.List) {
 			next := item.Next()
 			l.Remove(item)
 			delete(tw.timer, task.key)
+			tw.unpersist(task.key)
 			item = next
 			continue
 		}
@@ -209,10 +452,13 @@ func (tw *TimeWheel) scanAddRunTask(l *list.List) {
 			continue
 		}
 
-		go task.job(task.taskData)
+		if tw.pool.submit(firedJob{key: task.key, job: task.job, taskData: task.taskData, done: tw.recordJobDuration}) {
+			atomic.AddUint64(&tw.tasksFired, 1)
+		}
 		next := item.Next()
 		l.Remove(item)
 		delete(tw.timer, task.key)
+		tw.unpersist(task.key)
 		item = next
 
 		//周期任务重新添加到轮盘
@@ -220,7 +466,9 @@ func (tw *TimeWheel) scanAddRunTask(l *list.List) {
 			if task.times > 0 {
 				task.times--
 			}
-			tw.addTask(task)
+			if task.times != 0 {
+				tw.addTaskLocked(task)
+			}
 		}
 	}
 }